@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/onordander/pgvet/lint"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+func runFix(wOut, wErr io.Writer, patterns []string, opts options) int {
+	log := newLogger(wErr)
+
+	linter, ok := newLinter(log, opts)
+	if !ok {
+		return 1
+	}
+
+	files, err := findFiles(patterns)
+	if err != nil {
+		log.Error(err.Error())
+		return 1
+	}
+	if len(files) == 0 {
+		log.Error("No files found for patterns: %v", patterns)
+		return 1
+	}
+
+	sources, err := lint.ReadFiles(files)
+	if err != nil {
+		log.Error("Failed to read file %s", err.Error())
+		return 1
+	}
+
+	fixed, err := linter.Fix(sources)
+	if err != nil {
+		logLintError(log, err)
+		return 1
+	}
+
+	var numFixed int
+	for i, src := range fixed {
+		if src.SQL == sources[i].SQL {
+			continue
+		}
+		numFixed++
+
+		if opts.diff {
+			if err := writeDiff(wOut, sources[i], src); err != nil {
+				log.Error("Failed to diff file %q: %s", src.Name, err.Error())
+				return 1
+			}
+			continue
+		}
+
+		info, err := os.Stat(src.Name)
+		if err != nil {
+			log.Error("Failed to write file %s", err.Error())
+			return 1
+		}
+		if err := os.WriteFile(src.Name, []byte(src.SQL), info.Mode()); err != nil {
+			log.Error("Failed to write file %s", err.Error())
+			return 1
+		}
+	}
+
+	log.Info("Fixed %d file(s)\n", numFixed)
+	return 0
+}
+
+func writeDiff(w io.Writer, before, after lint.Source) error {
+	return difflib.WriteUnifiedDiff(w, difflib.UnifiedDiff{
+		A:        difflib.SplitLines(before.SQL),
+		B:        difflib.SplitLines(after.SQL),
+		FromFile: "a/" + diffPath(before.Name),
+		ToFile:   "b/" + diffPath(after.Name),
+		Context:  3,
+	})
+}
+
+// diffPath returns the path as it follows the a/ and b/ prefixes of a diff, with forward slashes and no leading slash.
+func diffPath(path string) string {
+	return strings.TrimPrefix(filepath.ToSlash(path), "/")
+}
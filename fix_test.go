@@ -0,0 +1,103 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFix(t *testing.T) {
+	t.Parallel()
+
+	input := mustReadFile(t, "testdata/fix/migration.sql")
+	expected := mustReadFile(t, "testdata/fix/migration.fixed.sql")
+
+	t.Run("Should rewrite the files", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "migration.sql")
+		mustWriteFile(t, input, path)
+
+		var wOut, wErr strings.Builder
+		rc := runFix(&wOut, &wErr, []string{path}, options{})
+		require.Zero(t, rc, wErr.String())
+
+		assert.Equal(t, expected, mustReadFile(t, path))
+		assert.Empty(t, wOut.String())
+		assert.Contains(t, wErr.String(), "Fixed 1 file(s)")
+
+		// The fixed file has nothing left to fix
+		rc = runFix(&wOut, &wErr, []string{path}, options{})
+		require.Zero(t, rc, wErr.String())
+		assert.Contains(t, wErr.String(), "Fixed 0 file(s)")
+	})
+
+	t.Run("Should print a diff", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "migration.sql")
+		mustWriteFile(t, input, path)
+
+		var wOut, wErr strings.Builder
+		rc := runFix(&wOut, &wErr, []string{path}, options{diff: true})
+		require.Zero(t, rc, wErr.String())
+
+		// The file is left as is
+		assert.Equal(t, input, mustReadFile(t, path))
+
+		out := wOut.String()
+		// The temporary directory is absolute, its leading slash is left out
+		assert.Contains(t, out, "--- a/"+strings.TrimPrefix(filepath.ToSlash(path), "/")+"\n")
+		assert.Contains(t, out, "+++ b/"+strings.TrimPrefix(filepath.ToSlash(path), "/")+"\n")
+		assert.NotContains(t, out, "a//")
+		assert.Contains(t, out, "-CREATE TABLE pgvet (\n+CREATE TABLE IF NOT EXISTS pgvet (\n")
+		assert.Contains(t, out, "-DROP INDEX other_idx;\n+DROP INDEX IF EXISTS other_idx;\n")
+	})
+
+	t.Run("Should only add CONCURRENTLY and validations outside of a transaction", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "migration.sql")
+		mustWriteFile(t, input, path)
+		config := filepath.Join(t.TempDir(), "config.yaml")
+		mustWriteFile(t, "implicitTransaction: false\n", config)
+
+		var wOut, wErr strings.Builder
+		rc := runFix(&wOut, &wErr, []string{path}, options{configpath: &config})
+		require.Zero(t, rc, wErr.String())
+
+		fixed := mustReadFile(t, path)
+		assert.Contains(t, fixed, "REFERENCES pgvet(id) NOT VALID;\nALTER TABLE other VALIDATE CONSTRAINT reference_fk;\n")
+		assert.Contains(t, fixed, "DROP INDEX CONCURRENTLY IF EXISTS other_idx;\n")
+	})
+
+	t.Run("Should only apply enabled rules", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "migration.sql")
+		mustWriteFile(t, "CREATE TABLE pgvet (id int);\n", path)
+		config := filepath.Join(t.TempDir(), "config.yaml")
+		mustWriteFile(t, "rules:\n  missing-if-not-exists:\n    enabled: false\n", config)
+
+		var wOut, wErr strings.Builder
+		rc := runFix(&wOut, &wErr, []string{path}, options{configpath: &config})
+		require.Zero(t, rc, wErr.String())
+		assert.Equal(t, "CREATE TABLE pgvet (id int);\n", mustReadFile(t, path))
+	})
+
+	t.Run("Should not fix files with syntax errors", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "migration.sql")
+		mustWriteFile(t, "CREATE TABLE pgvet (id int);\nCREATE ERROR test;\n", path)
+
+		var wOut, wErr strings.Builder
+		rc := runFix(&wOut, &wErr, []string{path}, options{})
+		require.NotZero(t, rc)
+		assert.Contains(t, wErr.String(), "Failed to parse SQL")
+		assert.Equal(t, "CREATE TABLE pgvet (id int);\nCREATE ERROR test;\n", mustReadFile(t, path))
+	})
+}
@@ -0,0 +1,114 @@
+package lint
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/onordander/pgvet/rules"
+)
+
+// baselineVersion is the version of the baseline file format.
+const baselineVersion = 1
+
+// Baseline is the violations accepted when adopting pgvet, e.g. in migrations that have already been applied. Only
+// violations that aren't in the baseline are reported.
+type Baseline struct {
+	Version    int             `json:"version"`
+	Violations []BaselineEntry `json:"violations"`
+}
+
+// BaselineEntry is an accepted violation. It's matched by the file, the rule and the fingerprint of the statement, so
+// it still matches when other statements are added to the file or the statement is reformatted.
+type BaselineEntry struct {
+	File        string     `json:"file"`
+	Code        rules.Code `json:"code"`
+	Fingerprint string     `json:"fingerprint"`
+	// Describes the violation for whoever reads the file, it isn't used for matching.
+	Message string `json:"message,omitempty"`
+}
+
+func (e BaselineEntry) key() string {
+	return cleanPath(e.File) + "\x00" + string(e.Code) + "\x00" + e.Fingerprint
+}
+
+// cleanPath normalizes the path of a file, so it matches however the path was written when the file was linted.
+func cleanPath(path string) string {
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// NewBaseline returns a baseline that accepts the violations of the report.
+func NewBaseline(r Report) Baseline {
+	entries := []BaselineEntry{}
+	for _, v := range r {
+		entries = append(entries, BaselineEntry{File: cleanPath(v.File), Code: v.Code, Fingerprint: v.Fingerprint(), Message: v.Message})
+	}
+	// Sorted so the file doesn't change when the report order does
+	slices.SortStableFunc(entries, func(a, b BaselineEntry) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Code, b.Code), cmp.Compare(a.Fingerprint, b.Fingerprint))
+	})
+	return Baseline{Version: baselineVersion, Violations: entries}
+}
+
+// LoadBaseline reads the baseline file at path.
+func LoadBaseline(path string) (Baseline, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Baseline{}, err
+	}
+
+	var baseline Baseline
+	if err := json.Unmarshal(content, &baseline); err != nil {
+		return Baseline{}, err
+	}
+	if baseline.Version != baselineVersion {
+		return Baseline{}, fmt.Errorf("unsupported baseline version %d", baseline.Version)
+	}
+	return baseline, nil
+}
+
+// Write writes the baseline to the file at path.
+func (b Baseline) Write(path string) error {
+	content, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0o644)
+}
+
+// Filter returns the violations of the report that aren't in the baseline, and the stale entries of the baseline
+// that no violation matches. An entry accepts a single violation, so a statement repeated in a file is reported again
+// when it's added once more. Only entries of the linted files can be stale, the others weren't checked.
+func (b Baseline) Filter(r Report, files []string) (Report, []BaselineEntry) {
+	accepted := map[string]int{}
+	for _, entry := range b.Violations {
+		accepted[entry.key()]++
+	}
+
+	var filtered Report
+	for _, v := range r {
+		key := BaselineEntry{File: v.File, Code: v.Code, Fingerprint: v.Fingerprint()}.key()
+		if accepted[key] > 0 {
+			accepted[key]--
+			continue
+		}
+		filtered = append(filtered, v)
+	}
+
+	linted := map[string]bool{}
+	for _, file := range files {
+		linted[cleanPath(file)] = true
+	}
+	var stale []BaselineEntry
+	for _, entry := range b.Violations {
+		if !linted[cleanPath(entry.File)] || accepted[entry.key()] == 0 {
+			continue
+		}
+		accepted[entry.key()]--
+		stale = append(stale, entry)
+	}
+	return filtered, stale
+}
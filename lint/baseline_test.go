@@ -0,0 +1,98 @@
+package lint
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaseline(t *testing.T) {
+	t.Parallel()
+
+	linter := New(DefaultConfig())
+	lintSQL := func(t *testing.T, sql string) Report {
+		t.Helper()
+		report, err := linter.LintSQL("001.sql", sql)
+		require.NoError(t, err)
+		return report
+	}
+
+	t.Run("Should round trip", func(t *testing.T) {
+		t.Parallel()
+
+		baseline := NewBaseline(lintSQL(t, "DROP TABLE IF EXISTS pgvet;\n"))
+		require.Len(t, baseline.Violations, 1)
+		assert.Equal(t, "001.sql", baseline.Violations[0].File)
+		assert.Equal(t, "Dropping table public.pgvet", baseline.Violations[0].Message)
+
+		path := filepath.Join(t.TempDir(), "baseline.json")
+		require.NoError(t, baseline.Write(path))
+		loaded, err := LoadBaseline(path)
+		require.NoError(t, err)
+		assert.Equal(t, baseline, loaded)
+	})
+
+	t.Run("Should leave out accepted violations", func(t *testing.T) {
+		t.Parallel()
+
+		baseline := NewBaseline(lintSQL(t, "DROP TABLE IF EXISTS pgvet;\n"))
+
+		// Reformatting and new statements don't affect the accepted violation
+		report := lintSQL(t, "ALTER TABLE other DROP COLUMN IF EXISTS id;\n\ndrop   table if exists pgvet;\n")
+		filtered, stale := baseline.Filter(report, []string{"001.sql"})
+		require.Len(t, filtered, 1)
+		assert.Equal(t, "ALTER TABLE other DROP COLUMN IF EXISTS id", filtered[0].Statement)
+		assert.Empty(t, stale)
+	})
+
+	t.Run("Should accept a violation once", func(t *testing.T) {
+		t.Parallel()
+
+		baseline := NewBaseline(lintSQL(t, "DROP TABLE IF EXISTS pgvet;\n"))
+		filtered, _ := baseline.Filter(lintSQL(t, "DROP TABLE IF EXISTS pgvet;\nDROP TABLE IF EXISTS pgvet;\n"), []string{"001.sql"})
+		assert.Len(t, filtered, 1)
+	})
+
+	t.Run("Should report stale entries of linted files", func(t *testing.T) {
+		t.Parallel()
+
+		baseline := NewBaseline(lintSQL(t, "DROP TABLE IF EXISTS pgvet;\n"))
+		filtered, stale := baseline.Filter(nil, []string{"001.sql"})
+		assert.Empty(t, filtered)
+		require.Len(t, stale, 1)
+		assert.Equal(t, baseline.Violations[0], stale[0])
+
+		_, stale = baseline.Filter(nil, []string{"002.sql"})
+		assert.Empty(t, stale)
+	})
+
+	t.Run("Should match the files however their paths are written", func(t *testing.T) {
+		t.Parallel()
+
+		report, err := linter.LintSQL("./migrations/001.sql", "DROP TABLE IF EXISTS pgvet;\n")
+		require.NoError(t, err)
+		baseline := NewBaseline(report)
+		require.Len(t, baseline.Violations, 1)
+		assert.Equal(t, "migrations/001.sql", baseline.Violations[0].File)
+
+		report, err = linter.LintSQL("migrations/001.sql", "DROP TABLE IF EXISTS pgvet;\n")
+		require.NoError(t, err)
+		filtered, stale := baseline.Filter(report, []string{"migrations/001.sql"})
+		assert.Empty(t, filtered)
+		assert.Empty(t, stale)
+
+		_, stale = baseline.Filter(nil, []string{"./migrations/001.sql"})
+		assert.Len(t, stale, 1)
+	})
+
+	t.Run("Should reject unknown versions", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "baseline.json")
+		require.NoError(t, Baseline{Version: 2}.Write(path))
+		_, err := LoadBaseline(path)
+		assert.ErrorContains(t, err, "unsupported baseline version 2")
+	})
+}
@@ -0,0 +1,316 @@
+package lint
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/onordander/pgvet/rules"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+)
+
+// RuleConfig configures a single rule.
+type RuleConfig struct {
+	Enabled bool
+	// Overrides the default severity of the rule.
+	Severity rules.Severity
+	Options  rules.Options
+}
+
+// ruleConfigFile is a rule config as written in a config file. The fields left out keep their current value.
+type ruleConfigFile struct {
+	Enabled  *bool          `yaml:"enabled"`
+	Severity rules.Severity `yaml:"severity"`
+	// Decoded on top of the rule's default options.
+	Options ast.Node `yaml:"options"`
+}
+
+// MigrationTool is the tool the migrations are written for. It decides which parts of the files are linted and
+// whether they run inside a transaction.
+type MigrationTool string
+
+const (
+	// MigrationToolNone lints the files as plain SQL scripts.
+	MigrationToolNone MigrationTool = ""
+	// MigrationToolGoose lints the up sections of goose migrations, see https://github.com/pressly/goose
+	MigrationToolGoose MigrationTool = "goose"
+	// MigrationToolGolangMigrate pairs the NNN_name.up.sql and NNN_name.down.sql files of golang-migrate, see
+	// https://github.com/golang-migrate/migrate
+	MigrationToolGolangMigrate MigrationTool = "golang-migrate"
+	// MigrationToolFlyway orders the versioned, undo and repeatable migrations of Flyway and substitutes their
+	// placeholders, see https://documentation.red-gate.com/flyway
+	MigrationToolFlyway MigrationTool = "flyway"
+	// MigrationToolDbmate lints the up sections of dbmate migrations, see https://github.com/amacneil/dbmate
+	MigrationToolDbmate MigrationTool = "dbmate"
+	// MigrationToolSqitch lints the deploy scripts of sqitch changes in the order of the plan, see https://sqitch.org
+	MigrationToolSqitch MigrationTool = "sqitch"
+)
+
+var migrationTools = []MigrationTool{
+	MigrationToolNone, MigrationToolGoose, MigrationToolGolangMigrate, MigrationToolFlyway, MigrationToolDbmate,
+	MigrationToolSqitch,
+}
+
+// DownConfig configures the linting of down migrations, for the migration tools that have them.
+type DownConfig struct {
+	// Lint the down migrations too. The default depends on the migration tool.
+	Enabled *bool
+	// Replaces the config of the rules for the down migrations. The breaking change rules are disabled by default,
+	// reverting a migration is bound to drop what it created.
+	Rules map[rules.Code]RuleConfig
+}
+
+// RepeatableConfig configures the linting of repeatable migrations, which run again whenever they change.
+type RepeatableConfig struct {
+	// Replaces the config of the rules for the repeatable migrations. The idempotency rules are errors by default, a
+	// repeatable migration runs against the objects it created itself.
+	Rules map[rules.Code]RuleConfig
+}
+
+// FlywayConfig configures the linting of Flyway migrations.
+type FlywayConfig struct {
+	// The values substituted for the ${name} placeholders in the migrations.
+	Placeholders map[string]string `yaml:"placeholders"`
+}
+
+// SqitchConfig configures the linting of sqitch changes.
+type SqitchConfig struct {
+	// The plan that orders the changes, by default the sqitch.plan next to the deploy directory of the scripts.
+	Plan string `yaml:"plan"`
+}
+
+// GoConfig configures the linting of SQL embedded in Go files.
+type GoConfig struct {
+	// The functions and methods that take SQL as their first string argument, on top of Exec, Query and the like.
+	Functions []string `yaml:"functions"`
+}
+
+// Config decides which rules are run and how. Start from DefaultConfig to get the defaults of the rules, or leave the
+// rule maps nil for New to use the defaults.
+type Config struct {
+	// If true the linter will treat the migration as running inside a transaction by default.
+	ImplicitTransaction *bool
+	// The major version of the PostgreSQL server the migrations are deployed to.
+	PostgresVersion int
+	Rules           map[rules.Code]RuleConfig
+	MigrationTool   MigrationTool
+	Down            DownConfig
+	Repeatable      RepeatableConfig
+	Flyway          FlywayConfig
+	Sqitch          SqitchConfig
+	Go              GoConfig
+}
+
+// configFile is the config as written in a config file.
+type configFile struct {
+	ImplicitTransaction *bool                         `yaml:"implicitTransaction"`
+	PostgresVersion     int                           `yaml:"postgresVersion"`
+	Rules               map[rules.Code]ruleConfigFile `yaml:"rules"`
+	MigrationTool       MigrationTool                 `yaml:"migrationTool"`
+	Down                struct {
+		Enabled *bool                         `yaml:"enabled"`
+		Rules   map[rules.Code]ruleConfigFile `yaml:"rules"`
+	} `yaml:"down"`
+	Repeatable struct {
+		Rules map[rules.Code]ruleConfigFile `yaml:"rules"`
+	} `yaml:"repeatable"`
+	Flyway FlywayConfig `yaml:"flyway"`
+	Sqitch SqitchConfig `yaml:"sqitch"`
+	Go     GoConfig     `yaml:"go"`
+}
+
+// DefaultConfig returns the config with every rule set to its defaults.
+func DefaultConfig() Config {
+	ruleConfigs := map[rules.Code]RuleConfig{}
+	downRuleConfigs := map[rules.Code]RuleConfig{}
+	repeatableRuleConfigs := map[rules.Code]RuleConfig{}
+	for _, rule := range rules.AllRules() {
+		enabled := !rule.DisabledByDefault
+		ruleConfigs[rule.Code] = RuleConfig{
+			Enabled:  enabled,
+			Severity: rule.Severity,
+			Options:  rule.NewOptions(),
+		}
+		if rule.Breaking() {
+			downRuleConfigs[rule.Code] = RuleConfig{Severity: rule.Severity, Options: rule.NewOptions()}
+		}
+		if rule.Idempotency() {
+			repeatableRuleConfigs[rule.Code] = RuleConfig{Enabled: true, Severity: rules.SeverityError, Options: rule.NewOptions()}
+		}
+	}
+
+	implicitTx := true
+	return Config{
+		ImplicitTransaction: &implicitTx,
+		PostgresVersion:     rules.DefaultPostgresVersion,
+		Rules:               ruleConfigs,
+		Down:                DownConfig{Rules: downRuleConfigs},
+		Repeatable:          RepeatableConfig{Rules: repeatableRuleConfigs},
+	}
+}
+
+// LoadConfig reads the config file at path on top of the default config.
+func LoadConfig(path string) (Config, error) {
+	return OverlayConfig(DefaultConfig(), path)
+}
+
+// OverlayConfig reads the config file at path on top of cfg.
+func OverlayConfig(cfg Config, path string) (Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Config{}, err
+	}
+	defer f.Close()
+	return ReadConfig(cfg, f)
+}
+
+// ReadConfig reads a config file from r on top of cfg.
+func ReadConfig(cfg Config, r io.Reader) (Config, error) {
+	var parsed configFile
+	if err := yaml.NewDecoder(r).Decode(&parsed); err != nil {
+		return Config{}, err
+	}
+
+	if err := overlayRules(cfg.Rules, cfg.Rules, parsed.Rules); err != nil {
+		return Config{}, err
+	}
+
+	if parsed.ImplicitTransaction != nil {
+		cfg.ImplicitTransaction = parsed.ImplicitTransaction
+	}
+
+	if parsed.PostgresVersion != 0 {
+		if err := cfg.SetPostgresVersion(parsed.PostgresVersion); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if parsed.MigrationTool != MigrationToolNone {
+		if !slices.Contains(migrationTools, parsed.MigrationTool) {
+			return Config{}, fmt.Errorf("unknown migration tool %q", parsed.MigrationTool)
+		}
+		cfg.MigrationTool = parsed.MigrationTool
+	}
+	if parsed.Down.Enabled != nil {
+		cfg.Down.Enabled = parsed.Down.Enabled
+	}
+	if cfg.Down.Rules == nil {
+		cfg.Down.Rules = map[rules.Code]RuleConfig{}
+	}
+	if err := overlayRules(cfg.Down.Rules, cfg.Rules, parsed.Down.Rules); err != nil {
+		return Config{}, fmt.Errorf("down: %w", err)
+	}
+	if cfg.Repeatable.Rules == nil {
+		cfg.Repeatable.Rules = map[rules.Code]RuleConfig{}
+	}
+	if err := overlayRules(cfg.Repeatable.Rules, cfg.Rules, parsed.Repeatable.Rules); err != nil {
+		return Config{}, fmt.Errorf("repeatable: %w", err)
+	}
+
+	if len(parsed.Flyway.Placeholders) > 0 {
+		cfg.Flyway.Placeholders = maps.Clone(cfg.Flyway.Placeholders)
+		if cfg.Flyway.Placeholders == nil {
+			cfg.Flyway.Placeholders = map[string]string{}
+		}
+		maps.Copy(cfg.Flyway.Placeholders, parsed.Flyway.Placeholders)
+	}
+	if parsed.Sqitch.Plan != "" {
+		cfg.Sqitch.Plan = parsed.Sqitch.Plan
+	}
+	cfg.Go.Functions = append(slices.Clone(cfg.Go.Functions), parsed.Go.Functions...)
+
+	return cfg, nil
+}
+
+// overlayRules sets the fields of the rule configs parsed from a config file in ruleConfigs, validating them and
+// decoding their options. A rule missing from ruleConfigs starts from its config in base.
+func overlayRules(ruleConfigs, base map[rules.Code]RuleConfig, parsed map[rules.Code]ruleConfigFile) error {
+	for code, parsedConfig := range parsed {
+		if parsedConfig.Severity != "" && !parsedConfig.Severity.Valid() {
+			return fmt.Errorf("invalid severity %q for rule %q", parsedConfig.Severity, code)
+		}
+		ruleConfig, ok := ruleConfigs[code]
+		if !ok {
+			ruleConfig = base[code]
+		}
+		if parsedConfig.Enabled != nil {
+			ruleConfig.Enabled = *parsedConfig.Enabled
+		}
+		if parsedConfig.Severity != "" {
+			ruleConfig.Severity = parsedConfig.Severity
+		}
+		if rule, ok := rules.Lookup(code); ok {
+			if ruleConfig.Severity == "" {
+				ruleConfig.Severity = rule.Severity
+			}
+			if parsedConfig.Options != nil || ruleConfig.Options == nil {
+				opts, err := decodeOptions(rule, parsedConfig.Options)
+				if err != nil {
+					return fmt.Errorf("invalid options for rule %q: %w", code, err)
+				}
+				ruleConfig.Options = opts
+			}
+		} else if parsedConfig.Options != nil {
+			return fmt.Errorf("options set for unknown rule %q", code)
+		}
+		ruleConfigs[code] = ruleConfig
+	}
+	return nil
+}
+
+// downEnabled reports whether down migrations are linted, byDefault if not configured.
+func (c Config) downEnabled(byDefault bool) bool {
+	if c.Down.Enabled == nil {
+		return byDefault
+	}
+	return *c.Down.Enabled
+}
+
+// ruleConfigs returns the config of the rules for the kind of migration.
+func (c Config) ruleConfigs(m migration) map[rules.Code]RuleConfig {
+	var overrides map[rules.Code]RuleConfig
+	switch {
+	case m.down:
+		overrides = c.Down.Rules
+	case m.repeatable:
+		overrides = c.Repeatable.Rules
+	}
+	if len(overrides) == 0 {
+		return c.Rules
+	}
+	ruleConfigs := maps.Clone(c.Rules)
+	maps.Copy(ruleConfigs, overrides)
+	return ruleConfigs
+}
+
+// SetPostgresVersion sets the major version of the PostgreSQL server the migrations are deployed to.
+func (c *Config) SetPostgresVersion(version int) error {
+	if version < rules.MinPostgresVersion {
+		return fmt.Errorf("unsupported PostgreSQL version %d, the oldest supported version is %d", version, rules.MinPostgresVersion)
+	}
+	c.PostgresVersion = version
+	return nil
+}
+
+// decodeOptions decodes the options from the config file on top of the rule's defaults and validates them.
+func decodeOptions(rule rules.Rule, node ast.Node) (rules.Options, error) {
+	opts := rule.NewOptions()
+	if node == nil {
+		return opts, nil
+	}
+	if opts == nil {
+		return nil, fmt.Errorf("rule %q has no options", rule.Code)
+	}
+
+	if err := yaml.NodeToValue(node, opts, yaml.DisallowUnknownField()); err != nil {
+		return nil, err
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
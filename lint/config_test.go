@@ -0,0 +1,232 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/onordander/pgvet/rules"
+
+	"github.com/goccy/go-yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlayConfig(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should decode rule options", func(t *testing.T) {
+		t.Parallel()
+
+		path := mustWriteConfig(t, `
+rules:
+  multiple-locks:
+    enabled: true
+    options:
+      maxLocks: 3
+`)
+		cfg, err := LoadConfig(path)
+		require.NoError(t, err)
+
+		ruleCfg := cfg.Rules["multiple-locks"]
+		assert.True(t, ruleCfg.Enabled)
+		encoded, err := yaml.Marshal(ruleCfg.Options)
+		require.NoError(t, err)
+		assert.Equal(t, "maxLocks: 3\n", string(encoded))
+	})
+
+	t.Run("Should use default options", func(t *testing.T) {
+		t.Parallel()
+
+		path := mustWriteConfig(t, `
+rules:
+  multiple-locks:
+    enabled: true
+`)
+		cfg, err := LoadConfig(path)
+		require.NoError(t, err)
+
+		rule, ok := rules.Lookup("multiple-locks")
+		require.True(t, ok)
+		assert.Equal(t, rule.Options, cfg.Rules["multiple-locks"].Options)
+	})
+
+	t.Run("Should override severity", func(t *testing.T) {
+		t.Parallel()
+
+		path := mustWriteConfig(t, `
+rules:
+  drop-table:
+    enabled: true
+    severity: warning
+  drop-column:
+    enabled: true
+`)
+		cfg, err := LoadConfig(path)
+		require.NoError(t, err)
+
+		assert.Equal(t, rules.SeverityWarning, cfg.Rules["drop-table"].Severity)
+		assert.Equal(t, rules.SeverityError, cfg.Rules["drop-column"].Severity)
+	})
+
+	t.Run("Should keep the fields left out of a rule", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := DefaultConfig()
+		cfg.Rules["drop-table"] = RuleConfig{Enabled: true, Severity: rules.SeverityInfo}
+		cfg, err := OverlayConfig(cfg, mustWriteConfig(t, `
+rules:
+  drop-column:
+    severity: warning
+  drop-table:
+    enabled: false
+  missing-foreign-key-index:
+    options:
+      ignoreTables: [pgvet]
+`))
+		require.NoError(t, err)
+
+		assert.Equal(t, RuleConfig{Enabled: true, Severity: rules.SeverityWarning}, cfg.Rules["drop-column"])
+		assert.Equal(t, RuleConfig{Enabled: false, Severity: rules.SeverityInfo}, cfg.Rules["drop-table"])
+		ruleCfg := cfg.Rules["missing-foreign-key-index"]
+		assert.True(t, ruleCfg.Enabled)
+		assert.Equal(t, rules.SeverityWarning, ruleCfg.Severity)
+		encoded, err := yaml.Marshal(ruleCfg.Options)
+		require.NoError(t, err)
+		assert.Equal(t, "ignoreTables:\n- pgvet\n", string(encoded))
+	})
+
+	t.Run("Should read the config from a reader", func(t *testing.T) {
+		t.Parallel()
+
+		cfg, err := ReadConfig(DefaultConfig(), strings.NewReader("rules:\n  multiple-locks:\n    enabled: true\n    options:\n      maxLocks: 3\n"))
+		require.NoError(t, err)
+		assert.Equal(t, RuleConfig{Enabled: true, Severity: rules.SeverityWarning, Options: &rules.MultipleLocksOptions{MaxLocks: 3}}, cfg.Rules["multiple-locks"])
+	})
+
+	t.Run("Should set the PostgreSQL version", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := DefaultConfig()
+		assert.Equal(t, rules.DefaultPostgresVersion, cfg.PostgresVersion)
+
+		cfg, err := OverlayConfig(cfg, mustWriteConfig(t, "postgresVersion: 11\n"))
+		require.NoError(t, err)
+		assert.Equal(t, 11, cfg.PostgresVersion)
+
+		_, err = LoadConfig(mustWriteConfig(t, "postgresVersion: 8\n"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Should set the migration tool", func(t *testing.T) {
+		t.Parallel()
+
+		cfg, err := LoadConfig(mustWriteConfig(t, "migrationTool: goose\ndown:\n  enabled: true\n"))
+		require.NoError(t, err)
+		assert.Equal(t, MigrationToolGoose, cfg.MigrationTool)
+		assert.True(t, cfg.downEnabled(false))
+
+		cfg, err = LoadConfig(mustWriteConfig(t, "migrationTool: sqitch\nsqitch:\n  plan: db/sqitch.plan\n"))
+		require.NoError(t, err)
+		assert.Equal(t, MigrationToolSqitch, cfg.MigrationTool)
+		assert.Equal(t, "db/sqitch.plan", cfg.Sqitch.Plan)
+
+		_, err = LoadConfig(mustWriteConfig(t, "migrationTool: liquibase\n"))
+		assert.EqualError(t, err, `unknown migration tool "liquibase"`)
+	})
+
+	t.Run("Should override the rules of down migrations", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := DefaultConfig()
+		assert.True(t, cfg.ruleConfigs(migration{})["drop-table"].Enabled)
+		assert.False(t, cfg.ruleConfigs(migration{down: true})["drop-table"].Enabled)
+
+		cfg, err := OverlayConfig(cfg, mustWriteConfig(t, `
+down:
+  rules:
+    drop-table:
+      enabled: true
+      severity: info
+    missing-if-exists:
+      enabled: false
+`))
+		require.NoError(t, err)
+		assert.Equal(t, RuleConfig{Enabled: true, Severity: rules.SeverityInfo}, cfg.ruleConfigs(migration{down: true})["drop-table"])
+		assert.False(t, cfg.ruleConfigs(migration{down: true})["missing-if-exists"].Enabled)
+		assert.True(t, cfg.ruleConfigs(migration{})["missing-if-exists"].Enabled)
+		assert.False(t, cfg.ruleConfigs(migration{down: true})["drop-column"].Enabled)
+
+		_, err = LoadConfig(mustWriteConfig(t, "down:\n  rules:\n    drop-table:\n      severity: critical\n"))
+		assert.EqualError(t, err, `down: invalid severity "critical" for rule "drop-table"`)
+	})
+
+	t.Run("Should configure Flyway", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := DefaultConfig()
+		assert.Equal(t, rules.SeverityError, cfg.ruleConfigs(migration{repeatable: true})["missing-if-exists"].Severity)
+
+		cfg, err := OverlayConfig(cfg, mustWriteConfig(t, `
+migrationTool: flyway
+flyway:
+  placeholders:
+    schema: app
+repeatable:
+  rules:
+    missing-if-exists:
+      enabled: true
+      severity: warning
+`))
+		require.NoError(t, err)
+		assert.Equal(t, MigrationToolFlyway, cfg.MigrationTool)
+		assert.Equal(t, map[string]string{"schema": "app"}, cfg.Flyway.Placeholders)
+		assert.Equal(t, rules.SeverityWarning, cfg.ruleConfigs(migration{repeatable: true})["missing-if-exists"].Severity)
+		assert.Equal(t, rules.SeverityError, cfg.ruleConfigs(migration{repeatable: true})["missing-if-not-exists"].Severity)
+	})
+
+	t.Run("Should add the Go functions", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := DefaultConfig()
+		cfg.Go.Functions = []string{"migrate"}
+		cfg, err := OverlayConfig(cfg, mustWriteConfig(t, "go:\n  functions: [mustExec]\n"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"migrate", "mustExec"}, cfg.Go.Functions)
+	})
+
+	t.Run("Should fail on invalid severity", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := LoadConfig(mustWriteConfig(t, "rules:\n  drop-table:\n    severity: critical\n"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Should fail on invalid options", func(t *testing.T) {
+		t.Parallel()
+
+		cases := map[string]string{
+			"unknown option": "rules:\n  multiple-locks:\n    options:\n      maxTables: 3\n",
+			"invalid value":  "rules:\n  multiple-locks:\n    options:\n      maxLocks: 0\n",
+			"no options":     "rules:\n  drop-table:\n    options:\n      tables: [pgvet]\n",
+			"unknown rule":   "rules:\n  no-such-rule:\n    options:\n      maxLocks: 3\n",
+		}
+		for name, content := range cases {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				_, err := LoadConfig(mustWriteConfig(t, content))
+				assert.Error(t, err)
+			})
+		}
+	})
+}
+
+func mustWriteConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(content), 0o600)
+	require.NoError(t, err, "failed to write config: %q", path)
+	return path
+}
@@ -0,0 +1,81 @@
+package lint
+
+import (
+	"regexp"
+	"strings"
+)
+
+// dbmateMarker matches the comments that start the sections of a dbmate migration, e.g. -- migrate:up transaction:false
+var dbmateMarker = regexp.MustCompile(`^--\s*migrate:(up|down)(?:\s+(.*?))?\s*$`)
+
+// dbmateSection is the up or down section of a dbmate migration.
+type dbmateSection struct {
+	start, end int
+	// Set with the transaction:false option, dbmate runs the section outside of a transaction.
+	noTransaction bool
+}
+
+// dbmateMigrations lints the up sections of dbmate migrations, and the down sections too if enabled. Dbmate runs a
+// section inside a transaction unless its marker has the transaction:false option. Files without markers are linted as
+// plain SQL.
+func (l *Linter) dbmateMigrations(sources []Source) []migration {
+	var migrations []migration
+	for i, src := range sources {
+		up, down := parseDbmate(src.SQL)
+		if up == nil && down == nil {
+			migrations = append(migrations, l.plainMigration(src, i))
+			continue
+		}
+
+		if up != nil {
+			migrations = append(migrations, migration{
+				Source:              Source{Name: src.Name, SQL: blankOutside(src.SQL, [][2]int{{up.start, up.end}})},
+				source:              i,
+				implicitTransaction: !up.noTransaction,
+			})
+		}
+		if down != nil && l.cfg.downEnabled(false) {
+			migrations = append(migrations, migration{
+				Source:              Source{Name: src.Name, SQL: blankOutside(src.SQL, [][2]int{{down.start, down.end}})},
+				source:              i,
+				implicitTransaction: !down.noTransaction,
+				down:                true,
+			})
+		}
+	}
+	return migrations
+}
+
+// parseDbmate returns the sections following the migrate:up and migrate:down markers, nil for a missing section. The
+// marker lines are left out of the sections.
+func parseDbmate(sql string) (up, down *dbmateSection) {
+	// The section being read
+	var section *dbmateSection
+
+	offset := 0
+	for line := range strings.SplitAfterSeq(sql, "\n") {
+		lineStart := offset
+		offset += len(line)
+
+		match := dbmateMarker.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		if section != nil {
+			section.end = lineStart
+		}
+
+		section = &dbmateSection{start: offset, end: len(sql)}
+		for _, option := range strings.Fields(match[2]) {
+			if strings.EqualFold(option, "transaction:false") {
+				section.noTransaction = true
+			}
+		}
+		if match[1] == "up" {
+			up = section
+		} else {
+			down = section
+		}
+	}
+	return up, down
+}
@@ -0,0 +1,75 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/onordander/pgvet/rules"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const dbmateSQL = `-- migrate:up transaction:false
+CREATE INDEX CONCURRENTLY IF NOT EXISTS pgvet_idx ON pgvet(id);
+ALTER TABLE pgvet DROP COLUMN IF EXISTS value;
+
+-- migrate:down
+DROP INDEX CONCURRENTLY IF EXISTS pgvet_idx;
+`
+
+func TestParseDbmate(t *testing.T) {
+	t.Parallel()
+
+	up, down := parseDbmate(dbmateSQL)
+	require.NotNil(t, up)
+	require.NotNil(t, down)
+	assert.Equal(t, "CREATE INDEX CONCURRENTLY IF NOT EXISTS pgvet_idx ON pgvet(id);\nALTER TABLE pgvet DROP COLUMN IF EXISTS value;\n\n", dbmateSQL[up.start:up.end])
+	assert.True(t, up.noTransaction)
+	assert.Equal(t, "DROP INDEX CONCURRENTLY IF EXISTS pgvet_idx;\n", dbmateSQL[down.start:down.end])
+	assert.False(t, down.noTransaction)
+
+	up, down = parseDbmate("SELECT 1;\n-- migrate:upgrade\n")
+	assert.Nil(t, up)
+	assert.Nil(t, down)
+}
+
+func TestLintDbmate(t *testing.T) {
+	t.Parallel()
+
+	cfg := DefaultConfig()
+	cfg.MigrationTool = MigrationToolDbmate
+
+	t.Run("Should lint the up section", func(t *testing.T) {
+		t.Parallel()
+
+		violations, err := New(cfg).LintSQL("db/migrations/20240101000000_pgvet.sql", dbmateSQL)
+		require.NoError(t, err)
+		require.Len(t, violations, 1)
+		assert.Equal(t, rules.Code("drop-column"), violations[0].Code)
+		assert.Equal(t, 3, violations[0].StatementLine)
+	})
+
+	t.Run("Should lint the down section if enabled", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := cfg
+		enabled := true
+		cfg.Down.Enabled = &enabled
+
+		// The down section runs inside a transaction
+		violations, err := New(cfg).LintSQL("db/migrations/20240101000000_pgvet.sql", dbmateSQL)
+		require.NoError(t, err)
+		require.Len(t, violations, 2)
+		assert.Equal(t, rules.Code("concurrent-in-tx"), violations[1].Code)
+		assert.Equal(t, 6, violations[1].StatementLine)
+	})
+
+	t.Run("Should lint files without markers as plain SQL", func(t *testing.T) {
+		t.Parallel()
+
+		violations, err := New(cfg).LintSQL("seed.sql", "CREATE INDEX CONCURRENTLY IF NOT EXISTS pgvet_idx ON pgvet(id);\n")
+		require.NoError(t, err)
+		require.Len(t, violations, 1)
+		assert.Equal(t, rules.Code("concurrent-in-tx"), violations[0].Code)
+	})
+}
@@ -0,0 +1,107 @@
+package lint
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/onordander/pgvet/rules"
+
+	pgquery "github.com/wasilibs/go-pgquery"
+)
+
+// Fixes can uncover new violations, e.g. a fixed statement that another rule can fix as well, so the sources are
+// linted again until there is nothing left to fix.
+const maxFixPasses = 5
+
+// FixSQL applies the automatic fixes of the enabled rules to a single migration.
+func (l *Linter) FixSQL(name, sql string) (string, error) {
+	fixed, err := l.Fix([]Source{{Name: name, SQL: sql}})
+	if err != nil {
+		return "", err
+	}
+	return fixed[0].SQL, nil
+}
+
+// Fix applies the automatic fixes of the enabled rules to the sources and returns the fixed sources in the same
+// order. Comments and formatting around the fixed statements are kept, violations disabled by nolint directives are
+// left as is.
+func (l *Linter) Fix(sources []Source) ([]Source, error) {
+	sources = slices.Clone(sources)
+	for range maxFixPasses {
+		migrations, err := l.migrations(sources)
+		if err != nil {
+			return nil, err
+		}
+		files, err := l.check(migrations)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			if len(file.syntaxErrors) > 0 {
+				return nil, &ParseError{File: file.Name, Err: file.syntaxErrors[0].err}
+			}
+		}
+
+		var fixed bool
+		for _, file := range files {
+			edits := file.fixes()
+			if file.down || file.embedded || len(edits) == 0 {
+				continue
+			}
+			// The linted SQL has the same offsets as the source unless it's mapped, the parts that aren't linted are only
+			// blanked out
+			sourceEdits := edits
+			if file.sourceMap != nil {
+				var ok bool
+				if sourceEdits, ok = file.sourceMap.edits(edits); !ok {
+					// The fix would change the value of a placeholder, leave it to the author
+					continue
+				}
+			}
+			linted, _, err := rules.ApplyEdits(file.SQL, edits)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fix file %q: %w", file.Name, err)
+			}
+			// Never return a broken migration
+			if _, err := pgquery.Parse(linted); err != nil {
+				return nil, fmt.Errorf("failed to fix file %q, the fixed SQL is invalid: %w", file.Name, err)
+			}
+			sql, _, err := rules.ApplyEdits(sources[file.source].SQL, sourceEdits)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fix file %q: %w", file.Name, err)
+			}
+			sources[file.source].SQL = sql
+			fixed = true
+		}
+		if !fixed {
+			break
+		}
+	}
+	return sources, nil
+}
+
+// fixes returns the edits of the fixers of the rules with violations in the file.
+func (f checkedFile) fixes() []rules.Edit {
+	var edits []rules.Edit
+	for _, ctx := range f.contexts {
+		if ctx.Rule.Fix == nil {
+			continue
+		}
+
+		fixed := map[int32]bool{}
+		for _, res := range filterNoLints(f.SQL, ctx.Results()) {
+			if fixed[res.StmtStart] {
+				continue
+			}
+			fixed[res.StmtStart] = true
+
+			for _, stmt := range f.tree.GetStmts() {
+				if stmt.GetStmtLocation() == res.StmtStart {
+					edits = append(edits, ctx.Rule.Fix(&rules.FixContext{RuleContext: ctx, Source: f.SQL, Stmt: stmt})...)
+				}
+			}
+		}
+	}
+	return edits
+}
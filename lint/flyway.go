@@ -0,0 +1,183 @@
+package lint
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/onordander/pgvet/rules"
+)
+
+// flywayFile matches the file names of Flyway migrations: versioned V1__name.sql, undo U1__name.sql and repeatable
+// R__name.sql. The parts of a version are separated by dots or underscores, e.g. V1.2__name.sql or V1_2__name.sql.
+var flywayFile = regexp.MustCompile(`^(?:([VU])(\d+(?:[._]\d+)*)|R)__(.*)\.sql$`)
+
+// flywayPlaceholder matches the placeholders Flyway substitutes, e.g. ${schema}
+var flywayPlaceholder = regexp.MustCompile(`\$\{([^{}]+)\}`)
+
+// flywayFilenamePlaceholder is the built-in placeholder for the file name of the migration.
+const flywayFilenamePlaceholder = "flyway:filename"
+
+// flywayVersion is the version of a versioned or undo migration.
+type flywayVersion []string
+
+// parseFlywayVersion splits the version into its numeric parts.
+func parseFlywayVersion(version string) flywayVersion {
+	parts := strings.FieldsFunc(version, func(r rune) bool { return r == '.' || r == '_' })
+	for i, part := range parts {
+		parts[i] = strings.TrimLeft(part, "0")
+	}
+	// Trailing zeros don't count, 1.0 is the same version as 1
+	for len(parts) > 0 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	return parts
+}
+
+// compare compares the versions part by part numerically, so 2 comes before 10 and 1.2 before 1.10.
+func (v flywayVersion) compare(other flywayVersion) int {
+	for i := range max(len(v), len(other)) {
+		var a, b string
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(other) {
+			b = other[i]
+		}
+		// The parts have no leading zeros, so the longer number is the larger
+		if c := cmp.Or(cmp.Compare(len(a), len(b)), cmp.Compare(a, b)); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// String returns the version with its parts separated by dots, e.g. 1.2
+func (v flywayVersion) String() string {
+	if len(v) == 0 {
+		return "0"
+	}
+	parts := slices.Clone(v)
+	for i, part := range parts {
+		if part == "" {
+			parts[i] = "0"
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// flywayMigrationFile is a source named like a Flyway migration.
+type flywayMigrationFile struct {
+	index       int
+	version     flywayVersion
+	undo        bool
+	description string
+}
+
+// flywayMigrations orders the migrations like Flyway does: the versioned migrations by version, each followed by its
+// undo migration unless the down migrations are disabled, then the repeatable migrations by description. Versions
+// used by several migrations are reported. Files that aren't named like Flyway migrations are linted as plain SQL
+// after the others. The placeholders are substituted in all of them.
+func (l *Linter) flywayMigrations(sources []Source) []migration {
+	var versioned, repeatable []flywayMigrationFile
+	var plain []int
+	for i, src := range sources {
+		match := flywayFile.FindStringSubmatch(filepath.Base(src.Name))
+		switch {
+		case match == nil:
+			plain = append(plain, i)
+		case match[1] == "":
+			repeatable = append(repeatable, flywayMigrationFile{index: i, description: match[3]})
+		default:
+			versioned = append(versioned, flywayMigrationFile{
+				index:       i,
+				version:     parseFlywayVersion(match[2]),
+				undo:        match[1] == "U",
+				description: match[3],
+			})
+		}
+	}
+
+	slices.SortStableFunc(versioned, func(a, b flywayMigrationFile) int {
+		// The undo migration comes after the migration it reverts
+		return cmp.Or(a.version.compare(b.version), compareBool(a.undo, b.undo))
+	})
+	slices.SortStableFunc(repeatable, func(a, b flywayMigrationFile) int {
+		return cmp.Compare(a.description, b.description)
+	})
+
+	var migrations []migration
+	for i, file := range versioned {
+		if file.undo && !l.cfg.downEnabled(true) {
+			continue
+		}
+		m := l.flywayMigration(sources[file.index], file.index)
+		m.down = file.undo
+		if i > 0 && versioned[i-1].undo == file.undo && versioned[i-1].version.compare(file.version) == 0 {
+			m.issues = append(m.issues, fileIssue{
+				code:    rules.DuplicateMigrationVersionCode,
+				message: fmt.Sprintf("Version %s is also used by %s", file.version, sources[versioned[i-1].index].Name),
+			})
+		}
+		migrations = append(migrations, m)
+	}
+	for _, file := range repeatable {
+		m := l.flywayMigration(sources[file.index], file.index)
+		m.repeatable = true
+		migrations = append(migrations, m)
+	}
+	for _, index := range plain {
+		migrations = append(migrations, l.flywayMigration(sources[index], index))
+	}
+	return migrations
+}
+
+// flywayMigration lints the source with the placeholders substituted.
+func (l *Linter) flywayMigration(src Source, index int) migration {
+	values := map[string]string{flywayFilenamePlaceholder: filepath.Base(src.Name)}
+	maps.Copy(values, l.cfg.Flyway.Placeholders)
+
+	m := l.plainMigration(src, index)
+	m.SQL, m.sourceMap = substitutePlaceholders(src.SQL, values)
+	return m
+}
+
+// substitutePlaceholders replaces the placeholders that have a value, the others are left as is. The source map is
+// nil if nothing was replaced.
+func substitutePlaceholders(sql string, values map[string]string) (string, *sourceMap) {
+	var b strings.Builder
+	m := &sourceMap{source: sql}
+	end := 0
+	for _, loc := range flywayPlaceholder.FindAllStringSubmatchIndex(sql, -1) {
+		value, ok := values[sql[loc[2]:loc[3]]]
+		if !ok {
+			continue
+		}
+		m.add(b.Len(), end, loc[0]-end)
+		b.WriteString(sql[end:loc[0]])
+		b.WriteString(value)
+		end = loc[1]
+	}
+	if end == 0 {
+		return sql, nil
+	}
+	m.add(b.Len(), end, len(sql)-end)
+	b.WriteString(sql[end:])
+	return b.String(), m
+}
+
+// compareBool orders false before true.
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,119 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/onordander/pgvet/rules"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlywayVersion(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "2", b: "10", expected: -1},
+		{a: "1.2", b: "1.10", expected: -1},
+		{a: "1.0", b: "1", expected: 0},
+		{a: "1_1", b: "1.1", expected: 0},
+		{a: "002", b: "2", expected: 0},
+		{a: "1.0.1", b: "1", expected: 1},
+		{a: "20240101", b: "3", expected: 1},
+	}
+	for _, c := range cases {
+		t.Run(c.a+" "+c.b, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, c.expected, parseFlywayVersion(c.a).compare(parseFlywayVersion(c.b)))
+			assert.Equal(t, -c.expected, parseFlywayVersion(c.b).compare(parseFlywayVersion(c.a)))
+		})
+	}
+
+	assert.Equal(t, "1.0.2", parseFlywayVersion("01_0_2").String())
+}
+
+func TestLintFlyway(t *testing.T) {
+	t.Parallel()
+
+	cfg := DefaultConfig()
+	cfg.MigrationTool = MigrationToolFlyway
+	cfg.Flyway.Placeholders = map[string]string{"schema": "app"}
+
+	type violation struct {
+		File     string
+		Code     rules.Code
+		Severity rules.Severity
+	}
+	violations := func(r Report) []violation {
+		var violations []violation
+		for _, v := range r {
+			violations = append(violations, violation{File: v.File, Code: v.Code, Severity: v.Severity})
+		}
+		return violations
+	}
+
+	t.Run("Should order the migrations by version", func(t *testing.T) {
+		t.Parallel()
+
+		report, err := New(cfg).Lint([]Source{
+			{Name: "sql/R__log.sql", SQL: "CREATE TABLE pgvet_log (id int);\n"},
+			{Name: "sql/U2__create.sql", SQL: "DROP TABLE pgvet;\n"},
+			{Name: "sql/V10__drop.sql", SQL: "DROP TABLE IF EXISTS pgvet;\n"},
+			{Name: "sql/V2__create.sql", SQL: "CREATE TABLE pgvet (id int);\n"},
+			{Name: "sql/V2.0__other.sql", SQL: "SELECT 1;\n"},
+			{Name: "sql/seed.sql", SQL: "DROP TABLE IF EXISTS pgvet;\n"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []violation{
+			{File: "sql/V2__create.sql", Code: "missing-if-not-exists", Severity: rules.SeverityWarning},
+			{File: "sql/V2.0__other.sql", Code: "duplicate-migration-version", Severity: rules.SeverityError},
+			// The undo migration doesn't break anything, but it's checked for idempotency like the others
+			{File: "sql/U2__create.sql", Code: "missing-if-exists", Severity: rules.SeverityWarning},
+			{File: "sql/V10__drop.sql", Code: "drop-table", Severity: rules.SeverityError},
+			// A repeatable migration runs again, it fails if it isn't idempotent
+			{File: "sql/R__log.sql", Code: "missing-if-not-exists", Severity: rules.SeverityError},
+			{File: "sql/seed.sql", Code: "drop-table", Severity: rules.SeverityError},
+		}, violations(report))
+		assert.Equal(t, "Version 2 is also used by sql/V2__create.sql", report[1].Message)
+	})
+
+	t.Run("Should substitute the placeholders", func(t *testing.T) {
+		t.Parallel()
+
+		sql := "-- ${flyway:filename}\nALTER TABLE ${schema}.pgvet DROP COLUMN IF EXISTS value;\n"
+		report, err := New(cfg).LintSQL("V1__drop.sql", sql)
+		require.NoError(t, err)
+		require.Len(t, report, 1)
+
+		// The violation is about the substituted SQL but located in the source
+		v := report[0]
+		assert.Equal(t, "Dropping column app.pgvet.value", v.Message)
+		assert.Equal(t, "-- ${flyway:filename}\nALTER TABLE ${schema}.pgvet DROP COLUMN IF EXISTS value", v.Statement)
+		assert.Equal(t, 1, v.StatementLine)
+		assert.Equal(t, 2, v.StartLine)
+		assert.Equal(t, 29, v.StartColumn)
+		assert.Equal(t, 2, v.EndLine)
+		assert.Equal(t, 56, v.EndColumn)
+	})
+
+	t.Run("Should report unknown placeholders as parse errors", func(t *testing.T) {
+		t.Parallel()
+
+		report, err := New(cfg).LintSQL("V1__drop.sql", "DROP TABLE IF EXISTS ${table};\n")
+		require.NoError(t, err)
+		require.Len(t, report, 1)
+		assert.Equal(t, rules.ParseErrorCode, report[0].Code)
+	})
+
+	t.Run("Should fix around the placeholders", func(t *testing.T) {
+		t.Parallel()
+
+		fixed, err := New(cfg).FixSQL("V1__index.sql", "DROP INDEX ${schema}.pgvet_idx;\n")
+		require.NoError(t, err)
+		assert.Equal(t, "DROP INDEX IF EXISTS ${schema}.pgvet_idx;\n", fixed)
+	})
+}
@@ -0,0 +1,285 @@
+package lint
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/onordander/pgvet/rules"
+)
+
+// goSQLFunctions are the functions and methods that take SQL as their first string argument, those of database/sql,
+// sqlx and pgx.
+var goSQLFunctions = []string{
+	"Exec", "ExecContext", "MustExec", "MustExecContext",
+	"Query", "QueryContext", "QueryRow", "QueryRowContext", "Queryx", "QueryxContext", "QueryRowx", "QueryRowxContext",
+}
+
+// goString is a string literal the SQL of a call concatenates.
+type goString struct {
+	lit *ast.BasicLit
+	// The constant the call refers to the literal by, nil if the literal is in the call.
+	ref *ast.Ident
+}
+
+// goSQL is the SQL extracted from a Go file, mapped back to the file.
+type goSQL struct {
+	b         strings.Builder
+	sourceMap *sourceMap
+}
+
+// goMigrations lints the SQL passed as string constants to the SQL functions in a Go file, the statements of each call
+// in the order of the file. Literals, constants and their concatenations are linted, SQL built at runtime isn't. The SQL
+// in the down function of a migration registered with goose is linted as a down migration if enabled, and a migration
+// registered without a transaction runs outside of one.
+func (l *Linter) goMigrations(m migration, source string) []migration {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, m.Name, source, parser.SkipObjectResolution)
+	if err != nil {
+		m.SQL = ""
+		m.embedded = true
+		m.issues = append(m.issues, fileIssue{code: rules.ParseErrorCode, message: "Invalid Go file: " + err.Error()})
+		return []migration{m}
+	}
+	base := fset.File(file.Pos()).Base()
+
+	functions := append(slices.Clone(goSQLFunctions), l.cfg.Go.Functions...)
+	constants := goConstants(file)
+	down, noTransaction, registered := gooseRegistration(file)
+
+	up, downSQL := &goSQL{sourceMap: &sourceMap{source: source}}, &goSQL{sourceMap: &sourceMap{source: source}}
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || !slices.Contains(functions, calledName(call)) {
+			return true
+		}
+		for _, arg := range call.Args {
+			strs, ok := constantString(arg, constants, 0)
+			if !ok {
+				continue
+			}
+			sql := up
+			if slices.ContainsFunc(down, func(fn ast.Node) bool { return fn.Pos() <= call.Pos() && call.End() <= fn.End() }) {
+				sql = downSQL
+			}
+			sql.add(strs, base)
+			break
+		}
+		return true
+	})
+
+	implicitTransaction := m.implicitTransaction
+	if registered {
+		implicitTransaction = !noTransaction
+	}
+	migrations := []migration{{
+		Source:              Source{Name: m.Name, SQL: up.b.String()},
+		source:              m.source,
+		implicitTransaction: implicitTransaction,
+		embedded:            true,
+		sourceMap:           up.sourceMap,
+		issues:              m.issues,
+	}}
+	if downSQL.b.Len() > 0 && l.cfg.downEnabled(false) {
+		migrations = append(migrations, migration{
+			Source:              Source{Name: m.Name, SQL: downSQL.b.String()},
+			source:              m.source,
+			implicitTransaction: implicitTransaction,
+			down:                true,
+			embedded:            true,
+			sourceMap:           downSQL.sourceMap,
+		})
+	}
+	return migrations
+}
+
+// add appends the SQL of a call. The SQL of the calls is separated by semicolons, on a line of their own so a comment
+// at the end of the SQL doesn't swallow them. The separator is located at the end of the last string of the call.
+func (s *goSQL) add(strs []goString, base int) {
+	start := s.b.Len()
+	for _, str := range strs {
+		if str.ref != nil {
+			// The literal is declared elsewhere, so its value is located at the reference to keep the SQL of the call
+			// in the order of the file
+			s.sourceMap.insert(s.b.Len(), int(str.ref.Pos())-base, len(constantValue(str.lit)))
+			s.b.WriteString(constantValue(str.lit))
+			continue
+		}
+		s.addLiteral(str.lit, base)
+	}
+	if strings.HasSuffix(strings.TrimSpace(s.b.String()[start:]), ";") {
+		return
+	}
+	end := int(strs[len(strs)-1].lit.End()) - base - 1
+	if ref := strs[len(strs)-1].ref; ref != nil {
+		end = int(ref.End()) - base
+	}
+	s.sourceMap.insert(s.b.Len(), end, len("\n;"))
+	s.b.WriteString("\n;")
+}
+
+// addLiteral appends the value of a string literal. The parts of the value that are written as is in the literal are
+// mapped to the file, escape sequences aren't.
+func (s *goSQL) addLiteral(lit *ast.BasicLit, base int) {
+	// The offset of the value in the file, after the opening quote
+	offset := int(lit.Pos()) - base + 1
+	value := lit.Value[1 : len(lit.Value)-1]
+	if lit.Value[0] == '`' {
+		s.sourceMap.add(s.b.Len(), offset, len(value))
+		s.b.WriteString(value)
+		return
+	}
+
+	for len(value) > 0 {
+		if value[0] != '\\' {
+			n := strings.IndexByte(value, '\\')
+			if n < 0 {
+				n = len(value)
+			}
+			s.sourceMap.add(s.b.Len(), offset, n)
+			s.b.WriteString(value[:n])
+			value, offset = value[n:], offset+n
+			continue
+		}
+
+		r, multibyte, tail, err := strconv.UnquoteChar(value, '"')
+		if err != nil {
+			// The parser accepted the literal, so the escapes are valid
+			return
+		}
+		if r < utf8.RuneSelf || !multibyte {
+			s.b.WriteByte(byte(r))
+		} else {
+			s.b.WriteRune(r)
+		}
+		offset += len(value) - len(tail)
+		value = tail
+	}
+}
+
+// constantValue returns the value of a string literal.
+func constantValue(lit *ast.BasicLit) string {
+	// The parser accepted the literal, so it can be unquoted
+	value, _ := strconv.Unquote(lit.Value)
+	return value
+}
+
+// constantString returns the string literals the expression concatenates, false if it isn't a string constant.
+func constantString(expr ast.Expr, constants map[string]ast.Expr, depth int) ([]goString, bool) {
+	// Constants can't be cyclic, but the constants of all scopes share the names here so a reference can loop
+	const maxDepth = 32
+	if depth > maxDepth {
+		return nil, false
+	}
+
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		return []goString{{lit: expr}}, expr.Kind == token.STRING
+	case *ast.ParenExpr:
+		return constantString(expr.X, constants, depth+1)
+	case *ast.Ident:
+		value, ok := constants[expr.Name]
+		if !ok {
+			return nil, false
+		}
+		strs, ok := constantString(value, constants, depth+1)
+		for i := range strs {
+			strs[i].ref = expr
+		}
+		return strs, ok
+	case *ast.BinaryExpr:
+		if expr.Op != token.ADD {
+			return nil, false
+		}
+		x, ok := constantString(expr.X, constants, depth+1)
+		if !ok {
+			return nil, false
+		}
+		y, ok := constantString(expr.Y, constants, depth+1)
+		if !ok {
+			return nil, false
+		}
+		return append(x, y...), true
+	}
+	return nil, false
+}
+
+// goConstants returns the values of the constants declared in the file by name.
+func goConstants(file *ast.File) map[string]ast.Expr {
+	constants := map[string]ast.Expr{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		decl, ok := n.(*ast.GenDecl)
+		if !ok || decl.Tok != token.CONST {
+			return true
+		}
+		for _, spec := range decl.Specs {
+			spec := spec.(*ast.ValueSpec)
+			for i, name := range spec.Names {
+				if i < len(spec.Values) {
+					constants[name.Name] = spec.Values[i]
+				}
+			}
+		}
+		return true
+	})
+	return constants
+}
+
+// gooseRegistration returns the down functions of the migrations the file registers with goose, e.g.
+// goose.AddMigrationContext(up, down), and whether they're registered to run outside of a transaction.
+func gooseRegistration(file *ast.File) (down []ast.Node, noTransaction bool, registered bool) {
+	functions := map[string]*ast.FuncDecl{}
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+			functions[fn.Name.Name] = fn
+		}
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		name := calledName(call)
+		if !strings.HasPrefix(name, "AddMigration") && !strings.HasPrefix(name, "AddNamedMigration") {
+			return true
+		}
+		registered = true
+		noTransaction = noTransaction || strings.Contains(name, "NoTx")
+
+		// The up and down functions are the last arguments
+		var fns []ast.Node
+		for _, arg := range call.Args {
+			switch arg := arg.(type) {
+			case *ast.FuncLit:
+				fns = append(fns, arg)
+			case *ast.Ident:
+				if fn, ok := functions[arg.Name]; ok {
+					fns = append(fns, fn)
+				} else if arg.Name == "nil" {
+					fns = append(fns, nil)
+				}
+			}
+		}
+		if len(fns) >= 2 && fns[len(fns)-1] != nil {
+			down = append(down, fns[len(fns)-1])
+		}
+		return true
+	})
+	return down, noTransaction, registered
+}
+
+// calledName returns the name of the function or method called, e.g. ExecContext for tx.ExecContext(ctx, sql).
+func calledName(call *ast.CallExpr) string {
+	switch fn := call.Fun.(type) {
+	case *ast.Ident:
+		return fn.Name
+	case *ast.SelectorExpr:
+		return fn.Sel.Name
+	}
+	return ""
+}
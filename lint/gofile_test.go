@@ -0,0 +1,145 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/onordander/pgvet/rules"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gooseGo = "package migrations\n" +
+	"\n" +
+	"import (\n" +
+	"\t\"context\"\n" +
+	"\t\"database/sql\"\n" +
+	"\n" +
+	"\t\"github.com/pressly/goose/v3\"\n" +
+	")\n" +
+	"\n" +
+	"const table = \"pgvet\"\n" +
+	"\n" +
+	"func init() {\n" +
+	"\tgoose.AddMigrationContext(up, down)\n" +
+	"}\n" +
+	"\n" +
+	"func up(ctx context.Context, tx *sql.Tx) error {\n" +
+	"\tif _, err := tx.ExecContext(ctx, `ALTER TABLE pgvet\n" +
+	"\t\tDROP COLUMN IF EXISTS value`); err != nil {\n" +
+	"\t\treturn err\n" +
+	"\t}\n" +
+	"\t_, err := tx.ExecContext(ctx, \"CREATE INDEX IF NOT EXISTS pgvet_idx ON \"+table+\"(id);\")\n" +
+	"\treturn err\n" +
+	"}\n" +
+	"\n" +
+	"func down(ctx context.Context, tx *sql.Tx) error {\n" +
+	"\t_, err := tx.ExecContext(ctx, \"DROP TABLE IF EXISTS \"+table)\n" +
+	"\treturn err\n" +
+	"}\n"
+
+func TestLintGo(t *testing.T) {
+	t.Parallel()
+
+	codes := func(violations Report) []rules.Code {
+		var codes []rules.Code
+		for _, v := range violations {
+			codes = append(codes, v.Code)
+		}
+		return codes
+	}
+
+	t.Run("Should lint the SQL passed to the SQL functions", func(t *testing.T) {
+		t.Parallel()
+
+		violations, err := New(DefaultConfig()).LintSQL("20240101000000_pgvet.go", gooseGo)
+		require.NoError(t, err)
+		assert.Equal(t, []rules.Code{"drop-column", "non-concurrent-index"}, codes(violations))
+
+		// The locations are those of the file
+		assert.Equal(t, "ALTER TABLE pgvet\n\t\tDROP COLUMN IF EXISTS value", violations[0].Statement)
+		assert.Equal(t, 17, violations[0].StatementLine)
+		assert.Equal(t, 18, violations[0].StartLine)
+		assert.Equal(t, 3, violations[0].StartColumn)
+		assert.Equal(t, 21, violations[1].StatementLine)
+	})
+
+	t.Run("Should lint the down function if enabled", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := DefaultConfig()
+		enabled := true
+		cfg.Down.Enabled = &enabled
+		cfg.Down.Rules = map[rules.Code]RuleConfig{}
+
+		violations, err := New(cfg).LintSQL("20240101000000_pgvet.go", gooseGo)
+		require.NoError(t, err)
+		assert.Equal(t, []rules.Code{"drop-column", "non-concurrent-index", "drop-table"}, codes(violations))
+		assert.Equal(t, 26, violations[2].StartLine)
+		// The value of a constant is located at the reference to it
+		assert.Equal(t, "DROP TABLE IF EXISTS \"+table", violations[2].Statement)
+	})
+
+	t.Run("Should use the transaction mode of the registration", func(t *testing.T) {
+		t.Parallel()
+
+		source := "package migrations\n\n" +
+			"func init() {\n" +
+			"\tgoose.AddMigrationNoTxContext(up, nil)\n" +
+			"}\n\n" +
+			"func up(ctx context.Context, db *sql.DB) error {\n" +
+			"\t_, err := db.ExecContext(ctx, \"CREATE INDEX CONCURRENTLY IF NOT EXISTS pgvet_idx ON pgvet(id)\")\n" +
+			"\treturn err\n" +
+			"}\n"
+		violations, err := New(DefaultConfig()).LintSQL("1.go", source)
+		require.NoError(t, err)
+		assert.Empty(t, violations)
+
+		violations, err = New(DefaultConfig()).LintSQL("1.go", strings.Replace(source, "NoTx", "", 1))
+		require.NoError(t, err)
+		assert.Equal(t, []rules.Code{"concurrent-in-tx"}, codes(violations))
+	})
+
+	t.Run("Should decode the escapes", func(t *testing.T) {
+		t.Parallel()
+
+		source := "package db\n\nfunc run() {\n\tdb.Exec(\"SELECT 1;\\n\\tDROP TABLE IF EXISTS \\\"pgvet\\\"\")\n}\n"
+		violations, err := New(DefaultConfig()).LintSQL("db.go", source)
+		require.NoError(t, err)
+		require.Equal(t, []rules.Code{"drop-table"}, codes(violations))
+		assert.Equal(t, 4, violations[0].StartLine)
+	})
+
+	t.Run("Should lint the configured functions", func(t *testing.T) {
+		t.Parallel()
+
+		source := "package db\n\nfunc run() {\n\tmigrate(ctx, `DROP TABLE IF EXISTS pgvet`)\n\tdb.Exec(query)\n}\n"
+		violations, err := New(DefaultConfig()).LintSQL("db.go", source)
+		require.NoError(t, err)
+		assert.Empty(t, violations)
+
+		cfg := DefaultConfig()
+		cfg.Go.Functions = []string{"migrate"}
+		violations, err = New(cfg).LintSQL("db.go", source)
+		require.NoError(t, err)
+		assert.Equal(t, []rules.Code{"drop-table"}, codes(violations))
+	})
+
+	t.Run("Should report invalid Go files", func(t *testing.T) {
+		t.Parallel()
+
+		violations, err := New(DefaultConfig()).LintSQL("db.go", "package db\n\nfunc run() {\n")
+		require.NoError(t, err)
+		assert.Equal(t, []rules.Code{rules.ParseErrorCode}, codes(violations))
+	})
+
+	t.Run("Should not fix Go files", func(t *testing.T) {
+		t.Parallel()
+
+		source := "package db\n\nfunc run() {\n\tdb.Exec(`DROP INDEX pgvet_idx`)\n}\n"
+		fixed, err := New(DefaultConfig()).FixSQL("db.go", source)
+		require.NoError(t, err)
+		assert.Equal(t, source, fixed)
+	})
+}
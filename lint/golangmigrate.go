@@ -0,0 +1,92 @@
+package lint
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/onordander/pgvet/rules"
+)
+
+// golangMigrateFile matches the file names of golang-migrate migrations, e.g. 001_create_users.up.sql
+var golangMigrateFile = regexp.MustCompile(`^(\d+)_.*\.(up|down)\.sql$`)
+
+// golangMigrateVersion is the up and down files of a golang-migrate version, more than one of a kind if the version
+// is duplicated.
+type golangMigrateVersion struct {
+	up   []int
+	down []int
+}
+
+// golangMigrateMigrations orders the migrations by version like golang-migrate does, and lints each down migration
+// right after the up migration it reverts unless the down migrations are disabled. Versions without a down migration
+// and versions used by several files are reported. Files that aren't named like golang-migrate migrations are linted
+// as plain SQL after the others.
+func (l *Linter) golangMigrateMigrations(sources []Source) []migration {
+	versions := map[uint64]*golangMigrateVersion{}
+	var plain []int
+	for i, src := range sources {
+		match := golangMigrateFile.FindStringSubmatch(filepath.Base(src.Name))
+		if match == nil {
+			plain = append(plain, i)
+			continue
+		}
+		number, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			plain = append(plain, i)
+			continue
+		}
+		if versions[number] == nil {
+			versions[number] = &golangMigrateVersion{}
+		}
+		if match[2] == "up" {
+			versions[number].up = append(versions[number].up, i)
+		} else {
+			versions[number].down = append(versions[number].down, i)
+		}
+	}
+
+	var migrations []migration
+	for _, number := range slices.Sorted(maps.Keys(versions)) {
+		version := versions[number]
+		// The files after the first one of a kind are the duplicates
+		duplicate := func(files []int, i int) []fileIssue {
+			if i == 0 {
+				return nil
+			}
+			return []fileIssue{{
+				code:    rules.DuplicateMigrationVersionCode,
+				message: fmt.Sprintf("Version %d is also used by %s", number, sources[files[0]].Name),
+			}}
+		}
+
+		for i, index := range version.up {
+			m := l.plainMigration(sources[index], index)
+			m.issues = duplicate(version.up, i)
+			if len(version.down) == 0 {
+				m.issues = append(m.issues, fileIssue{
+					code:    rules.MissingDownMigrationCode,
+					message: fmt.Sprintf("Version %d has no down migration", number),
+				})
+			}
+			migrations = append(migrations, m)
+		}
+		if !l.cfg.downEnabled(true) {
+			continue
+		}
+		for i, index := range version.down {
+			m := l.plainMigration(sources[index], index)
+			m.down = true
+			m.issues = duplicate(version.down, i)
+			migrations = append(migrations, m)
+		}
+	}
+
+	for _, index := range plain {
+		migrations = append(migrations, l.plainMigration(sources[index], index))
+	}
+	return migrations
+}
@@ -0,0 +1,91 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/onordander/pgvet/rules"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintGolangMigrate(t *testing.T) {
+	t.Parallel()
+
+	cfg := DefaultConfig()
+	cfg.MigrationTool = MigrationToolGolangMigrate
+
+	sources := []Source{
+		{Name: "migrations/10_drop.down.sql", SQL: "CREATE TABLE IF NOT EXISTS pgvet (id text PRIMARY KEY);\n"},
+		{Name: "migrations/10_drop.up.sql", SQL: "DROP TABLE IF EXISTS pgvet;\n"},
+		{Name: "migrations/2_create.down.sql", SQL: "DROP TABLE IF EXISTS pgvet;\n"},
+		{Name: "migrations/2_create.up.sql", SQL: "CREATE TABLE IF NOT EXISTS pgvet (id text PRIMARY KEY);\n"},
+		{Name: "migrations/3_index.up.sql", SQL: "CREATE INDEX CONCURRENTLY IF NOT EXISTS pgvet_idx ON pgvet(id);\n"},
+		{Name: "migrations/3_other.up.sql", SQL: "SELECT 1;\n"},
+		{Name: "seed.sql", SQL: "DROP TABLE IF EXISTS pgvet;\n"},
+	}
+
+	type violation struct {
+		File    string
+		Code    rules.Code
+		Message string
+	}
+	violations := func(r Report) []violation {
+		var violations []violation
+		for _, v := range r {
+			violations = append(violations, violation{File: v.File, Code: v.Code, Message: v.Message})
+		}
+		return violations
+	}
+
+	t.Run("Should pair the up and down migrations by version", func(t *testing.T) {
+		t.Parallel()
+
+		report, err := New(cfg).Lint(sources)
+		require.NoError(t, err)
+		assert.Equal(t, []violation{
+			{File: "migrations/3_index.up.sql", Code: "concurrent-in-tx", Message: "Index pgvet_idx is created concurrently inside a transaction"},
+			{File: "migrations/3_index.up.sql", Code: "missing-down-migration", Message: "Version 3 has no down migration"},
+			{File: "migrations/3_other.up.sql", Code: "missing-down-migration", Message: "Version 3 has no down migration"},
+			{File: "migrations/3_other.up.sql", Code: "duplicate-migration-version", Message: "Version 3 is also used by migrations/3_index.up.sql"},
+			{File: "migrations/10_drop.up.sql", Code: "drop-table", Message: "Dropping table public.pgvet"},
+			{File: "seed.sql", Code: "drop-table", Message: "Dropping table public.pgvet"},
+		}, violations(report))
+
+		// The violations about the file point at its start
+		assert.Equal(t, 1, report[1].StartLine)
+		assert.Empty(t, report[1].Statement)
+	})
+
+	t.Run("Should use the rules of the down migrations", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := cfg
+		cfg.Down.Rules = map[rules.Code]RuleConfig{
+			"drop-table": {Enabled: true, Severity: rules.SeverityInfo},
+		}
+
+		report, err := New(cfg).Lint(sources[:4])
+		require.NoError(t, err)
+		require.Len(t, report, 2)
+		assert.Equal(t, "migrations/2_create.down.sql", report[0].File)
+		assert.Equal(t, rules.SeverityInfo, report[0].Severity)
+		assert.Equal(t, "migrations/10_drop.up.sql", report[1].File)
+		assert.Equal(t, rules.SeverityError, report[1].Severity)
+	})
+
+	t.Run("Should skip the down migrations if disabled", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := cfg
+		cfg.Down.Rules = map[rules.Code]RuleConfig{}
+		enabled := false
+		cfg.Down.Enabled = &enabled
+
+		report, err := New(cfg).Lint(sources[:4])
+		require.NoError(t, err)
+		assert.Equal(t, []violation{
+			{File: "migrations/10_drop.up.sql", Code: "drop-table", Message: "Dropping table public.pgvet"},
+		}, violations(report))
+	})
+}
@@ -0,0 +1,86 @@
+package lint
+
+import (
+	"regexp"
+	"strings"
+)
+
+// gooseAnnotation matches the comments goose reads its annotations from, e.g. -- +goose Up
+var gooseAnnotation = regexp.MustCompile(`(?i)^\s*--\s*\+goose\s+(.*?)\s*$`)
+
+// gooseMigration is a goose migration split into its sections.
+type gooseMigration struct {
+	up   [][2]int
+	down [][2]int
+	// Annotated with NO TRANSACTION, goose runs it outside of a transaction.
+	noTransaction bool
+}
+
+// gooseMigrations lints the up sections of goose migrations, and the down sections too if enabled. Goose runs a
+// migration inside a transaction unless it's annotated with NO TRANSACTION. Files without annotations are linted as
+// plain SQL.
+func (l *Linter) gooseMigrations(sources []Source) []migration {
+	var migrations []migration
+	for i, src := range sources {
+		goose, ok := parseGoose(src.SQL)
+		if !ok {
+			migrations = append(migrations, l.plainMigration(src, i))
+			continue
+		}
+
+		migrations = append(migrations, migration{
+			Source:              Source{Name: src.Name, SQL: blankOutside(src.SQL, goose.up)},
+			source:              i,
+			implicitTransaction: !goose.noTransaction,
+		})
+		if l.cfg.downEnabled(false) && len(goose.down) > 0 {
+			migrations = append(migrations, migration{
+				Source:              Source{Name: src.Name, SQL: blankOutside(src.SQL, goose.down)},
+				source:              i,
+				implicitTransaction: !goose.noTransaction,
+				down:                true,
+			})
+		}
+	}
+	return migrations
+}
+
+// parseGoose splits the SQL into the sections following the Up and Down annotations. The annotation lines are left
+// out of the sections, StatementBegin and StatementEnd only matter to the statement splitter of goose.
+func parseGoose(sql string) (gooseMigration, bool) {
+	var goose gooseMigration
+	var annotated bool
+	// The section being read, nil before the first Up or Down annotation
+	var section *[][2]int
+	start := 0
+
+	offset := 0
+	for line := range strings.SplitAfterSeq(sql, "\n") {
+		lineStart := offset
+		offset += len(line)
+
+		match := gooseAnnotation.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		annotated = true
+
+		if section != nil && start < lineStart {
+			*section = append(*section, [2]int{start, lineStart})
+		}
+		start = offset
+
+		switch strings.ToUpper(match[1]) {
+		case "UP":
+			section = &goose.up
+		case "DOWN":
+			section = &goose.down
+		case "NO TRANSACTION":
+			goose.noTransaction = true
+		}
+	}
+	if section != nil && start < len(sql) {
+		*section = append(*section, [2]int{start, len(sql)})
+	}
+	return goose, annotated
+}
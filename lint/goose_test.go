@@ -0,0 +1,141 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/onordander/pgvet/rules"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gooseSQL = `-- +goose Up
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION pgvet_now() RETURNS timestamptz AS $$
+BEGIN
+  RETURN now();
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+ALTER TABLE pgvet DROP COLUMN IF EXISTS value;
+
+-- +goose Down
+DROP TABLE IF EXISTS pgvet;
+`
+
+func TestParseGoose(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should split the sections", func(t *testing.T) {
+		t.Parallel()
+
+		goose, ok := parseGoose(gooseSQL)
+		require.True(t, ok)
+		// The annotation lines are left out
+		require.Len(t, goose.up, 2)
+		require.Len(t, goose.down, 1)
+		assert.True(t, strings.HasPrefix(gooseSQL[goose.up[0][0]:goose.up[0][1]], "CREATE OR REPLACE FUNCTION"))
+		assert.Equal(t, "ALTER TABLE pgvet DROP COLUMN IF EXISTS value;\n\n", gooseSQL[goose.up[1][0]:goose.up[1][1]])
+		assert.Equal(t, "DROP TABLE IF EXISTS pgvet;\n", gooseSQL[goose.down[0][0]:goose.down[0][1]])
+		assert.False(t, goose.noTransaction)
+	})
+
+	t.Run("Should read NO TRANSACTION", func(t *testing.T) {
+		t.Parallel()
+
+		goose, ok := parseGoose("-- +goose NO TRANSACTION\n-- +goose up\nSELECT 1;\n")
+		require.True(t, ok)
+		assert.True(t, goose.noTransaction)
+		assert.Len(t, goose.up, 1)
+	})
+
+	t.Run("Should ignore files without annotations", func(t *testing.T) {
+		t.Parallel()
+
+		_, ok := parseGoose("-- goose Up\nSELECT 1;\n")
+		assert.False(t, ok)
+	})
+}
+
+func TestLintGoose(t *testing.T) {
+	t.Parallel()
+
+	cfg := DefaultConfig()
+	cfg.MigrationTool = MigrationToolGoose
+
+	t.Run("Should lint the up section", func(t *testing.T) {
+		t.Parallel()
+
+		violations, err := New(cfg).LintSQL("20240101000000_pgvet.sql", gooseSQL)
+		require.NoError(t, err)
+		require.Len(t, violations, 1)
+		assert.Equal(t, rules.Code("drop-column"), violations[0].Code)
+		assert.Equal(t, "ALTER TABLE pgvet DROP COLUMN IF EXISTS value", violations[0].Statement)
+		// The lines are those of the file
+		assert.Equal(t, 9, violations[0].StatementLine)
+		assert.Equal(t, 9, violations[0].StartLine)
+	})
+
+	t.Run("Should lint the down section if enabled", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := cfg
+		enabled := true
+		cfg.Down.Enabled = &enabled
+
+		sources := []Source{
+			{Name: "1.sql", SQL: gooseSQL},
+			{Name: "2.sql", SQL: "-- +goose Up\nCREATE INDEX CONCURRENTLY IF NOT EXISTS pgvet_idx ON pgvet(id);\n"},
+		}
+		codes := func(violations Report) []rules.Code {
+			var codes []rules.Code
+			for _, v := range violations {
+				codes = append(codes, v.Code)
+			}
+			return codes
+		}
+
+		// The breaking change rules are disabled for down migrations by default
+		violations, err := New(cfg).Lint(sources)
+		require.NoError(t, err)
+		assert.Equal(t, []rules.Code{"drop-column", "concurrent-in-tx"}, codes(violations))
+
+		cfg.Down.Rules = map[rules.Code]RuleConfig{}
+		violations, err = New(cfg).Lint(sources)
+		require.NoError(t, err)
+		assert.Equal(t, []rules.Code{"drop-column", "drop-table", "concurrent-in-tx"}, codes(violations))
+		assert.Equal(t, 12, violations[1].StartLine)
+	})
+
+	t.Run("Should use the transaction mode of the file", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := cfg
+		implicitTx := false
+		cfg.ImplicitTransaction = &implicitTx
+
+		sql := "-- +goose Up\nCREATE INDEX CONCURRENTLY IF NOT EXISTS pgvet_idx ON pgvet(id);\n"
+		violations, err := New(cfg).LintSQL("1.sql", sql)
+		require.NoError(t, err)
+		require.Len(t, violations, 1)
+		assert.Equal(t, rules.Code("concurrent-in-tx"), violations[0].Code)
+
+		violations, err = New(cfg).LintSQL("1.sql", "-- +goose NO TRANSACTION\n"+sql)
+		require.NoError(t, err)
+		assert.Empty(t, violations)
+	})
+
+	t.Run("Should fix the up section", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := cfg
+		enabled := true
+		cfg.Down.Enabled = &enabled
+
+		sql := "-- +goose NO TRANSACTION\n-- +goose Up\nDROP INDEX pgvet_idx;\n\n-- +goose Down\nDROP INDEX pgvet_idx;\n"
+		fixed, err := New(cfg).FixSQL("1.sql", sql)
+		require.NoError(t, err)
+		assert.Equal(t, "-- +goose NO TRANSACTION\n-- +goose Up\nDROP INDEX CONCURRENTLY IF EXISTS pgvet_idx;\n\n-- +goose Down\nDROP INDEX pgvet_idx;\n", fixed)
+	})
+}
@@ -0,0 +1,379 @@
+// Package lint runs the pgvet rules against PostgreSQL migrations.
+//
+//	linter := lint.New(lint.DefaultConfig())
+//	violations, err := linter.LintSQL("001_init.sql", sql)
+package lint
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/onordander/pgvet/rules"
+
+	pganalyze "github.com/pganalyze/pg_query_go/v6"
+)
+
+// Violation is a rule violation found in a statement.
+type Violation struct {
+	File            string         `json:"file"`
+	Code            rules.Code     `json:"code"`
+	Severity        rules.Severity `json:"severity"`
+	Statement       string         `json:"statement"`
+	StatementLine   int            `json:"statementLine"`
+	StatementColumn int            `json:"statementColumn"`
+	// The part of the statement the violation is about, e.g. a sub-command or a constraint. Lines and columns start
+	// at 1, columns count characters and the end column is the one after the last character.
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+	// The position of the ALTER sub-command the violation is about, starting at 1. Zero if the violation is about the
+	// statement as a whole.
+	Subcommand int    `json:"subcommand,omitempty"`
+	Slug       string `json:"slug"`
+	Help       string `json:"help"`
+	// Describes the violation in the context of the statement, e.g. "Dropping column public.pgvet.id".
+	Message string `json:"message"`
+	// The database objects the violation is about, empty if they don't apply.
+	Schema     string `json:"schema,omitempty"`
+	Table      string `json:"table,omitempty"`
+	Column     string `json:"column,omitempty"`
+	Index      string `json:"index,omitempty"`
+	Constraint string `json:"constraint,omitempty"`
+}
+
+// Fingerprint identifies the violation by its file, rule and statement. Unlike the line it doesn't change when
+// other statements are added or the statement is reformatted, nor with the way the path of the file is written.
+func (v Violation) Fingerprint() string {
+	sum := sha256.Sum256([]byte(cleanPath(v.File) + "\x00" + string(v.Code) + "\x00" + rules.NormalizeSQL(v.Statement)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Report is the violations of the linted files, ordered by file and statement.
+type Report []Violation
+
+// Source is the SQL of a migration.
+type Source struct {
+	// The file name of the migration, used to report violations.
+	Name string
+	SQL  string
+}
+
+// ParseError is returned when the SQL of a source can't be parsed. Lint reports the statements that fail to parse as
+// violations of the parse-error rule instead, Fix refuses to fix the source.
+type ParseError struct {
+	File string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to parse SQL from file %q: %s", e.File, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Linter runs the rules enabled in its config. It is safe for concurrent use.
+type Linter struct {
+	// The number of files parsed concurrently, GOMAXPROCS if zero. The rules run in the order of the files.
+	Jobs int
+
+	cfg Config
+}
+
+// New returns a linter that runs the rules with the given config. The settings left unset get their defaults, rules
+// included: a nil rules map runs the rules enabled by default, an empty one runs none.
+func New(cfg Config) *Linter {
+	defaults := DefaultConfig()
+	if cfg.Rules == nil {
+		cfg.Rules = defaults.Rules
+	}
+	if cfg.Down.Rules == nil {
+		cfg.Down.Rules = defaults.Down.Rules
+	}
+	if cfg.Repeatable.Rules == nil {
+		cfg.Repeatable.Rules = defaults.Repeatable.Rules
+	}
+	if cfg.ImplicitTransaction == nil {
+		implicitTx := true
+		cfg.ImplicitTransaction = &implicitTx
+	}
+	if cfg.PostgresVersion == 0 {
+		cfg.PostgresVersion = rules.DefaultPostgresVersion
+	}
+	return &Linter{cfg: cfg}
+}
+
+// LintSQL lints a single migration.
+func (l *Linter) LintSQL(name, sql string) ([]Violation, error) {
+	return l.Lint([]Source{{Name: name, SQL: sql}})
+}
+
+// LintFiles reads and lints the files. The files are linted in the given order, which should be the order the
+// migrations are applied in.
+func (l *Linter) LintFiles(paths []string) (Report, error) {
+	sources, err := ReadFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	return l.Lint(sources)
+}
+
+// Lint lints the sources in the given order. The schema built by the earlier sources is visible to the rules when
+// linting the later ones.
+func (l *Linter) Lint(sources []Source) (Report, error) {
+	migrations, err := l.migrations(sources)
+	if err != nil {
+		return nil, err
+	}
+	files, err := l.check(migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	var report Report
+	for _, file := range files {
+		ruleConfigs := l.cfg.ruleConfigs(file.migration)
+		query := file.SQL
+		if file.sourceMap != nil {
+			// Report the text and the lines of the source
+			query = file.sourceMap.source
+		}
+		for _, res := range file.results() {
+			if file.sourceMap != nil {
+				res = file.sourceMap.locate(res)
+			}
+			statementLine := countLines(query[:res.StmtStart], query[res.StmtStart:res.StmtEnd])
+			raw := query[res.StmtStart:res.StmtEnd]
+			stmt := strings.TrimSpace(raw)
+			_, statementColumn := position(query, int(res.StmtStart)+len(raw)-len(strings.TrimLeftFunc(raw, unicode.IsSpace)))
+			startLine, startColumn := position(query, int(res.Start))
+			endLine, endColumn := position(query, int(res.End))
+			message := res.Message
+			if message == "" {
+				message = res.Slug
+			}
+			entry := Violation{
+				File:            file.Name,
+				Code:            res.Code,
+				Severity:        ruleConfigs[res.Code].Severity,
+				Statement:       stmt,
+				StatementLine:   statementLine,
+				StatementColumn: statementColumn,
+				StartLine:       startLine,
+				StartColumn:     startColumn,
+				EndLine:         endLine,
+				EndColumn:       endColumn,
+				Subcommand:      res.Subcommand,
+				Slug:            res.Slug,
+				Help:            res.Help,
+				Message:         message,
+				Schema:          res.Objects.Schema,
+				Table:           res.Objects.Table,
+				Column:          res.Objects.Column,
+				Index:           res.Objects.Index,
+				Constraint:      res.Objects.Constraint,
+			}
+			report = append(report, entry)
+		}
+	}
+	return report, nil
+}
+
+// EnabledRules returns the rules enabled in the config for any kind of migration, in the order of rules.AllRules.
+func (l *Linter) EnabledRules() []rules.Rule {
+	kinds := []map[rules.Code]RuleConfig{
+		l.cfg.ruleConfigs(migration{}),
+		l.cfg.ruleConfigs(migration{down: true}),
+		l.cfg.ruleConfigs(migration{repeatable: true}),
+	}
+	var enabled []rules.Rule
+	for _, rule := range rules.AllRules() {
+		if slices.ContainsFunc(kinds, func(ruleConfigs map[rules.Code]RuleConfig) bool { return ruleConfigs[rule.Code].Enabled }) {
+			enabled = append(enabled, rule)
+		}
+	}
+	return enabled
+}
+
+// enabledRules returns the rules enabled in ruleConfigs, in the order of rules.AllRules.
+func enabledRules(ruleConfigs map[rules.Code]RuleConfig) []rules.Rule {
+	var enabled []rules.Rule
+	for _, rule := range rules.AllRules() {
+		if cfg, ok := ruleConfigs[rule.Code]; ok && cfg.Enabled {
+			enabled = append(enabled, rule)
+		}
+	}
+	return enabled
+}
+
+// ReadFiles reads the files into sources named by their paths.
+func ReadFiles(paths []string) ([]Source, error) {
+	var sources []Source
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, Source{Name: path, SQL: string(content)})
+	}
+	return sources, nil
+}
+
+// checkedFile is a migration that the enabled rules have been run against.
+type checkedFile struct {
+	migration
+	tree *pganalyze.ParseResult
+	// The statements left out of the tree because they can't be parsed.
+	syntaxErrors []syntaxError
+	contexts     []*rules.RuleContext
+}
+
+// check parses the migrations concurrently and runs the enabled rules against them in order. Each migration is checked
+// against the schema built by the migrations before it, a single catalog updated as the rules go.
+func (l *Linter) check(migrations []migration) ([]checkedFile, error) {
+	files := make([]checkedFile, len(migrations))
+	err := l.parallel(len(migrations), func(i int) error {
+		tree, syntaxErrors, err := parse(migrations[i].SQL)
+		if err != nil {
+			return &ParseError{File: migrations[i].Name, Err: err}
+		}
+		files[i] = checkedFile{migration: migrations[i], tree: tree, syntaxErrors: syntaxErrors}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The rules can see the schema the migrations end up with
+	finalCatalog := rules.NewCatalog()
+	for _, file := range files {
+		if !file.down {
+			finalCatalog.ApplyTree(file.tree, file.Name)
+		}
+	}
+
+	catalog := rules.NewCatalog()
+	for i, file := range files {
+		ruleConfigs := l.cfg.ruleConfigs(file.migration)
+		var contexts []*rules.RuleContext
+		for _, rule := range enabledRules(ruleConfigs) {
+			contexts = append(contexts, &rules.RuleContext{
+				Rule:                rule,
+				Options:             ruleConfigs[rule.Code].Options,
+				File:                file.Name,
+				Source:              file.SQL,
+				ImplicitTransaction: file.implicitTransaction,
+				PostgresVersion:     l.cfg.PostgresVersion,
+				FinalCatalog:        finalCatalog,
+			})
+		}
+
+		// A down migration is checked against the schema but doesn't change it for the migrations after it
+		if file.down {
+			catalog.Savepoint()
+		}
+		err := rules.Check(file.tree, catalog, contexts)
+		if file.down {
+			catalog.Rollback()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to lint file %q: %w", file.Name, err)
+		}
+		files[i].contexts = contexts
+	}
+	return files, nil
+}
+
+// parallel calls fn for 0..n-1 on at most l.Jobs goroutines. The error of the lowest failing index is returned so
+// the outcome doesn't depend on the scheduling.
+func (l *Linter) parallel(n int, fn func(i int) error) error {
+	jobs := l.Jobs
+	if jobs <= 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+	jobs = min(jobs, n)
+
+	errs := make([]error, n)
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// results returns the violations in the file ordered by statement, leaving out the ones disabled by nolint directives.
+func (f checkedFile) results() []rules.Result {
+	var results []rules.Result
+	for _, ctx := range f.contexts {
+		results = append(results, ctx.Results()...)
+		if ctx.Rule.Code == rules.ParseErrorCode {
+			for _, syntaxErr := range f.syntaxErrors {
+				results = append(results, syntaxErr.result(ctx.Rule, f.SQL))
+			}
+		}
+		for _, issue := range f.issues {
+			if issue.code == ctx.Rule.Code {
+				results = append(results, issue.result(ctx.Rule))
+			}
+		}
+	}
+
+	// Keep the results of a rule in a statement together, in the order they were reported
+	slices.SortStableFunc(results, func(a, b rules.Result) int {
+		return cmp.Compare(a.StmtStart, b.StmtStart)
+	})
+	return filterNoLints(f.SQL, results)
+}
+
+// position returns the line and column of the offset in src.
+func position(src string, offset int) (int, int) {
+	before := src[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := utf8.RuneCountInString(before[strings.LastIndex(before, "\n")+1:]) + 1
+	return line, column
+}
+
+func countLines(precedingContent string, content string) int {
+	precedingNumLines := len(strings.Split(strings.ReplaceAll(precedingContent, "\r\n", "\n"), "\n"))
+
+	// The statement can start with blank lines too which will be trimmed, so count them now
+	var numLines int
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			numLines += 1
+			continue
+		}
+		break
+	}
+
+	return precedingNumLines + numLines
+}
@@ -0,0 +1,211 @@
+package lint
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/onordander/pgvet/rules"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintSQL(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should report violations", func(t *testing.T) {
+		t.Parallel()
+
+		sql := "CREATE TABLE IF NOT EXISTS pgvet (id int);\n\nALTER TABLE pgvet DROP COLUMN IF EXISTS id;\n"
+		violations, err := New(DefaultConfig()).LintSQL("001.sql", sql)
+		require.NoError(t, err)
+		require.Len(t, violations, 1)
+
+		assert.Equal(t, Violation{
+			File:            "001.sql",
+			Code:            "drop-column",
+			Severity:        rules.SeverityError,
+			Statement:       "ALTER TABLE pgvet DROP COLUMN IF EXISTS id",
+			StatementLine:   3,
+			StatementColumn: 1,
+			StartLine:       3,
+			StartColumn:     19,
+			EndLine:         3,
+			EndColumn:       43,
+			Subcommand:      1,
+			Slug:            violations[0].Slug,
+			Help:            violations[0].Help,
+			Message:         "Dropping column public.pgvet.id",
+			Schema:          "public",
+			Table:           "pgvet",
+			Column:          "id",
+		}, violations[0])
+		assert.NotEmpty(t, violations[0].Slug)
+	})
+
+	t.Run("Should respect the config", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := DefaultConfig()
+		cfg.Rules["drop-column"] = RuleConfig{Enabled: false}
+		violations, err := New(cfg).LintSQL("001.sql", "ALTER TABLE pgvet DROP COLUMN IF EXISTS id;")
+		require.NoError(t, err)
+		assert.Empty(t, violations)
+	})
+
+	t.Run("Should report parse errors", func(t *testing.T) {
+		t.Parallel()
+
+		sql := "SELECT 1;\nCREATE TABLE pgvet (id int,);\nALTER TABLE pgvet DROP COLUMN IF EXISTS id;\n"
+		violations, err := New(DefaultConfig()).LintSQL("001.sql", sql)
+		require.NoError(t, err)
+		require.Len(t, violations, 2)
+
+		assert.Equal(t, rules.ParseErrorCode, violations[0].Code)
+		assert.Equal(t, rules.SeverityError, violations[0].Severity)
+		assert.Equal(t, "Syntax error at or near \")\"", violations[0].Message)
+		assert.Equal(t, "CREATE TABLE pgvet (id int,)", violations[0].Statement)
+		assert.Equal(t, []int{2, 28, 2, 29}, []int{violations[0].StartLine, violations[0].StartColumn, violations[0].EndLine, violations[0].EndColumn})
+
+		// The statements after the broken one are still linted
+		assert.Equal(t, rules.Code("drop-column"), violations[1].Code)
+		assert.Equal(t, 3, violations[1].StatementLine)
+	})
+
+	t.Run("Should disable parse errors", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := DefaultConfig()
+		cfg.Rules[rules.ParseErrorCode] = RuleConfig{Enabled: false}
+		violations, err := New(cfg).LintSQL("001.sql", "CREATE TABLE;")
+		require.NoError(t, err)
+		assert.Empty(t, violations)
+	})
+
+	t.Run("Should default an empty config", func(t *testing.T) {
+		t.Parallel()
+
+		violations, err := New(Config{}).LintSQL("001.sql", "ALTER TABLE pgvet DROP COLUMN IF EXISTS id;")
+		require.NoError(t, err)
+		require.Len(t, violations, 1)
+		assert.Equal(t, rules.Code("drop-column"), violations[0].Code)
+
+		violations, err = New(Config{Rules: map[rules.Code]RuleConfig{}}).LintSQL("001.sql", "ALTER TABLE pgvet DROP COLUMN IF EXISTS id;")
+		require.NoError(t, err)
+		assert.Empty(t, violations)
+	})
+}
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+
+	sources := []Source{
+		{Name: "001.sql", SQL: "CREATE TABLE IF NOT EXISTS pgvet (id int, parent_id int REFERENCES parent(id));"},
+		{Name: "002.sql", SQL: "CREATE INDEX CONCURRENTLY IF NOT EXISTS pgvet_parent_idx ON pgvet(parent_id);"},
+	}
+
+	cfg := DefaultConfig()
+	implicitTx := false
+	cfg.ImplicitTransaction = &implicitTx
+
+	// The index in the second file covers the foreign key in the first
+	report, err := New(cfg).Lint(sources)
+	require.NoError(t, err)
+	assert.Empty(t, report)
+
+	report, err = New(cfg).Lint(sources[:1])
+	require.NoError(t, err)
+	require.Len(t, report, 1)
+	assert.Equal(t, rules.Code("missing-foreign-key-index"), report[0].Code)
+}
+
+func TestViolationPosition(t *testing.T) {
+	t.Parallel()
+
+	sql := "SELECT 1; ALTER TABLE pgvet ADD COLUMN IF NOT EXISTS a int,\r\n  DROP COLUMN IF EXISTS \"é\",\n  ADD COLUMN IF NOT EXISTS b int;\n"
+	violations, err := New(DefaultConfig()).LintSQL("001.sql", sql)
+	require.NoError(t, err)
+	require.Len(t, violations, 1)
+
+	v := violations[0]
+	assert.Equal(t, 1, v.StatementLine)
+	assert.Equal(t, 11, v.StatementColumn)
+	assert.Equal(t, 2, v.StartLine)
+	assert.Equal(t, 3, v.StartColumn)
+	assert.Equal(t, 2, v.EndLine)
+	assert.Equal(t, 28, v.EndColumn)
+}
+
+func TestViolationFingerprint(t *testing.T) {
+	t.Parallel()
+
+	linter := New(DefaultConfig())
+	before, err := linter.LintSQL("001.sql", "ALTER TABLE pgvet DROP COLUMN IF EXISTS id;")
+	require.NoError(t, err)
+	after, err := linter.LintSQL("001.sql", "SELECT 1;\n\n-- Moved and reformatted\nalter table pgvet\n  drop column if exists id;")
+	require.NoError(t, err)
+	other, err := linter.LintSQL("002.sql", "ALTER TABLE pgvet DROP COLUMN IF EXISTS id;")
+	require.NoError(t, err)
+
+	require.Len(t, before, 1)
+	require.Len(t, after, 1)
+	require.Len(t, other, 1)
+	assert.Len(t, before[0].Fingerprint(), 64)
+	assert.Equal(t, before[0].Fingerprint(), after[0].Fingerprint())
+	assert.NotEqual(t, before[0].Fingerprint(), other[0].Fingerprint())
+}
+
+func TestLintJobs(t *testing.T) {
+	t.Parallel()
+
+	var sources []Source
+	for i := range 20 {
+		sources = append(sources, Source{
+			Name: fmt.Sprintf("%03d.sql", i),
+			SQL:  fmt.Sprintf("CREATE TABLE pgvet_%d (id int);\nALTER TABLE pgvet_%d ADD COLUMN name text NOT NULL;\n", i+1, i),
+		})
+	}
+
+	cfg := DefaultConfig()
+	sequential := New(cfg)
+	sequential.Jobs = 1
+	expected, err := sequential.Lint(sources)
+	require.NoError(t, err)
+	require.NotEmpty(t, expected)
+
+	concurrent := New(cfg)
+	concurrent.Jobs = 8
+	report, err := concurrent.Lint(sources)
+	require.NoError(t, err)
+	assert.Equal(t, expected, report)
+
+	// Files that fail to parse don't stop the others from being linted
+	sources[3].SQL = "CREATE TABLE;"
+	sources[15].SQL = "CREATE TABLE;"
+	report, err = concurrent.Lint(sources)
+	require.NoError(t, err)
+	var parseErrors []string
+	for _, v := range report {
+		if v.Code == rules.ParseErrorCode {
+			parseErrors = append(parseErrors, v.File)
+		}
+	}
+	assert.Equal(t, []string{"003.sql", "015.sql"}, parseErrors)
+}
+
+func TestFixSQL(t *testing.T) {
+	t.Parallel()
+
+	sql := "-- pgvet_nolint:missing-if-exists\nDROP TABLE legacy;\n\nCREATE INDEX pgvet_idx ON pgvet(id);\n"
+	fixed, err := New(DefaultConfig()).FixSQL("001.sql", sql)
+	require.NoError(t, err)
+	// CONCURRENTLY can't be used in the implicit transaction
+	assert.Equal(t, "-- pgvet_nolint:missing-if-exists\nDROP TABLE legacy;\n\nCREATE INDEX IF NOT EXISTS pgvet_idx ON pgvet(id);\n", fixed)
+
+	cfg := DefaultConfig()
+	implicitTx := false
+	cfg.ImplicitTransaction = &implicitTx
+	fixed, err = New(cfg).FixSQL("001.sql", sql)
+	require.NoError(t, err)
+	assert.Equal(t, "-- pgvet_nolint:missing-if-exists\nDROP TABLE legacy;\n\nCREATE INDEX CONCURRENTLY IF NOT EXISTS pgvet_idx ON pgvet(id);\n", fixed)
+}
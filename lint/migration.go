@@ -0,0 +1,99 @@
+package lint
+
+import (
+	"path/filepath"
+
+	"github.com/onordander/pgvet/rules"
+)
+
+// migration is a source prepared for linting according to the migration tool.
+type migration struct {
+	// The SQL is the part of the source that is linted. The rest is blanked out, so locations and lines still point
+	// into the source, unless the sourceMap says otherwise.
+	Source
+	// The index of the source the migration was read from.
+	source int
+	// If true the migration runs inside a transaction unless it says otherwise.
+	implicitTransaction bool
+	// A down migration reverts the migration before it. It's checked against the schema the migrations before it
+	// have built up, but it's left out of the schema the migrations after it see. Down migrations aren't fixed.
+	down bool
+	// A repeatable migration runs again whenever it changes, so it has to succeed against the objects it created.
+	repeatable bool
+	// The SQL is embedded in a program, e.g. in the string literals of a Go file. It isn't fixed.
+	embedded bool
+	// Maps the locations in the SQL to the source if the SQL isn't the source with parts blanked out, nil otherwise.
+	sourceMap *sourceMap
+	// Violations about the migration as a whole, e.g. how it relates to the other migrations.
+	issues []fileIssue
+}
+
+// fileIssue is a violation of a rule about a migration as a whole rather than one of its statements.
+type fileIssue struct {
+	code    rules.Code
+	message string
+}
+
+// result returns the violation, it points at the start of the file without a statement.
+func (i fileIssue) result(rule rules.Rule) rules.Result {
+	return rules.Result{Slug: rule.Slug, Help: rule.Help, Code: rule.Code, Message: i.message}
+}
+
+// migrations prepares the sources for linting according to the migration tool. The SQL embedded in Go files is
+// extracted whatever the tool.
+func (l *Linter) migrations(sources []Source) ([]migration, error) {
+	migrations, err := l.toolMigrations(sources)
+	if err != nil {
+		return nil, err
+	}
+
+	var extracted []migration
+	for _, m := range migrations {
+		if filepath.Ext(m.Name) != ".go" {
+			extracted = append(extracted, m)
+			continue
+		}
+		extracted = append(extracted, l.goMigrations(m, sources[m.source].SQL)...)
+	}
+	return extracted, nil
+}
+
+// toolMigrations prepares the sources for linting according to the migration tool.
+func (l *Linter) toolMigrations(sources []Source) ([]migration, error) {
+	switch l.cfg.MigrationTool {
+	case MigrationToolGoose:
+		return l.gooseMigrations(sources), nil
+	case MigrationToolGolangMigrate:
+		return l.golangMigrateMigrations(sources), nil
+	case MigrationToolFlyway:
+		return l.flywayMigrations(sources), nil
+	case MigrationToolDbmate:
+		return l.dbmateMigrations(sources), nil
+	case MigrationToolSqitch:
+		return l.sqitchMigrations(sources)
+	}
+
+	var migrations []migration
+	for i, src := range sources {
+		migrations = append(migrations, l.plainMigration(src, i))
+	}
+	return migrations, nil
+}
+
+// plainMigration lints the whole source with the configured transaction behavior.
+func (l *Linter) plainMigration(src Source, index int) migration {
+	return migration{Source: src, source: index, implicitTransaction: *l.cfg.ImplicitTransaction}
+}
+
+// blankOutside blanks out everything in the SQL but the given ranges.
+func blankOutside(sql string, keep [][2]int) string {
+	var b []byte
+	end := 0
+	for _, r := range keep {
+		b = append(b, blank(sql[end:r[0]])...)
+		b = append(b, sql[r[0]:r[1]]...)
+		end = r[1]
+	}
+	b = append(b, blank(sql[end:])...)
+	return string(b)
+}
@@ -0,0 +1,156 @@
+package lint
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/onordander/pgvet/rules"
+
+	pganalyze "github.com/pganalyze/pg_query_go/v6"
+	pgquery "github.com/wasilibs/go-pgquery"
+	"github.com/wasilibs/go-pgquery/parser"
+)
+
+// syntaxError is a statement that can't be parsed.
+type syntaxError struct {
+	err error
+	// The offset in the source the parser gave up at.
+	offset int
+	// The range of the statement in the source, including the terminating semicolon.
+	start int
+	end   int
+}
+
+// parse parses the SQL, leaving out the statements that can't be parsed. The statements are split on the semicolons
+// found by the scanner, and the broken ones are blanked out with whitespace so the locations in the tree are still
+// offsets in the SQL. If the broken statement can't be told apart the whole SQL is left out.
+func parse(sql string) (*pganalyze.ParseResult, []syntaxError, error) {
+	src := sql
+	var syntaxErrors []syntaxError
+	for {
+		tree, err := pgquery.Parse(src)
+		if err == nil {
+			return tree, syntaxErrors, nil
+		}
+
+		var pgErr *parser.Error
+		if !errors.As(err, &pgErr) {
+			return nil, nil, err
+		}
+		offset := cursorOffset(src, pgErr.Cursorpos)
+		stmt, ok := brokenStatement(src, offset)
+		if !ok {
+			whole := syntaxError{err: err, offset: offset, start: 0, end: len(sql)}
+			return &pganalyze.ParseResult{}, []syntaxError{whole}, nil
+		}
+		syntaxErrors = append(syntaxErrors, syntaxError{err: err, offset: offset, start: stmt[0], end: stmt[1]})
+		// The semicolon is kept so the next statement still starts after it
+		end := stmt[0] + len(strings.TrimSuffix(src[stmt[0]:stmt[1]], ";"))
+		src = src[:stmt[0]] + blank(src[stmt[0]:end]) + src[end:]
+	}
+}
+
+// brokenStatement returns the range of the statement the parser gave up at, including its semicolon.
+func brokenStatement(src string, offset int) ([2]int, bool) {
+	statements, err := splitStatements(src)
+	if err != nil {
+		return [2]int{}, false
+	}
+	stmt, ok := statementAt(statements, offset)
+	// A statement that is blank already can't be the broken one, blanking it again would never end
+	if !ok || strings.TrimSpace(strings.TrimSuffix(src[stmt[0]:stmt[1]], ";")) == "" {
+		return [2]int{}, false
+	}
+	return stmt, true
+}
+
+// result returns the violation of the parse-error rule for the statement.
+func (e syntaxError) result(rule rules.Rule, sql string) rules.Result {
+	// The statement ends before the semicolon and the whitespace in front of it
+	stmt := strings.TrimSuffix(sql[e.start:e.end], ";")
+	end := e.start + len(strings.TrimRightFunc(stmt, unicode.IsSpace))
+
+	// Point at the character the parser gave up at, or the last one if it gave up at the end
+	start := e.offset
+	if start >= end {
+		_, size := utf8.DecodeLastRuneInString(sql[:end])
+		start = end - size
+	}
+	_, size := utf8.DecodeRuneInString(sql[start:])
+
+	message := e.err.Error()
+	return rules.Result{
+		Slug:      rule.Slug,
+		Help:      rule.Help,
+		Code:      rule.Code,
+		Message:   strings.ToUpper(message[:1]) + message[1:],
+		StmtStart: int32(e.start),
+		StmtEnd:   int32(e.start + len(stmt)),
+		Start:     int32(start),
+		End:       int32(start + size),
+	}
+}
+
+// splitStatements returns the ranges of the statements of the SQL, each ending after its semicolon.
+func splitStatements(sql string) ([][2]int, error) {
+	scan, err := pgquery.Scan(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	var statements [][2]int
+	start := 0
+	for _, tok := range scan.GetTokens() {
+		if tok.GetToken() == pganalyze.Token_ASCII_59 {
+			statements = append(statements, [2]int{start, int(tok.GetEnd())})
+			start = int(tok.GetEnd())
+		}
+	}
+	if start < len(sql) {
+		statements = append(statements, [2]int{start, len(sql)})
+	}
+	return statements, nil
+}
+
+// statementAt returns the statement the offset is in. An offset at the end of the SQL is in the last statement.
+func statementAt(statements [][2]int, offset int) ([2]int, bool) {
+	for _, stmt := range statements {
+		if offset >= stmt[0] && offset < stmt[1] {
+			return stmt, true
+		}
+	}
+	if len(statements) > 0 && offset == statements[len(statements)-1][1] {
+		return statements[len(statements)-1], true
+	}
+	return [2]int{}, false
+}
+
+// cursorOffset converts the 1-based character position of a parse error to a byte offset in the SQL. The position
+// is zero if unknown, which is treated as the end of the SQL.
+func cursorOffset(sql string, cursorpos int) int {
+	if cursorpos <= 0 {
+		return len(sql)
+	}
+	offset := 0
+	for range cursorpos - 1 {
+		if offset >= len(sql) {
+			break
+		}
+		_, size := utf8.DecodeRuneInString(sql[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// blank replaces every byte but the line breaks with a space, keeping the offsets and lines of the text around it.
+func blank(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c != '\n' && c != '\r' {
+			b[i] = ' '
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,67 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should parse valid SQL", func(t *testing.T) {
+		t.Parallel()
+
+		tree, syntaxErrors, err := parse("SELECT 1;\nSELECT 2;\n")
+		require.NoError(t, err)
+		assert.Empty(t, syntaxErrors)
+		assert.Len(t, tree.GetStmts(), 2)
+	})
+
+	t.Run("Should leave out the broken statements", func(t *testing.T) {
+		t.Parallel()
+
+		sql := "SELECT 'é';\nCREATE TABLE;\nSELECT 1;\nDROP;\nSELECT 2"
+		tree, syntaxErrors, err := parse(sql)
+		require.NoError(t, err)
+
+		require.Len(t, syntaxErrors, 2)
+		assert.Equal(t, "\nCREATE TABLE;", sql[syntaxErrors[0].start:syntaxErrors[0].end])
+		assert.Equal(t, ";", sql[syntaxErrors[0].offset:syntaxErrors[0].offset+1])
+		assert.Equal(t, "\nDROP;", sql[syntaxErrors[1].start:syntaxErrors[1].end])
+
+		// The locations of the statements that parse are offsets in the SQL
+		var statements []string
+		for _, stmt := range tree.GetStmts() {
+			end := stmt.GetStmtLocation() + stmt.GetStmtLen()
+			if stmt.GetStmtLen() == 0 {
+				end = int32(len(sql))
+			}
+			statements = append(statements, sql[stmt.GetStmtLocation():end])
+		}
+		assert.Equal(t, []string{"SELECT 'é'", "\nSELECT 1", "\nSELECT 2"}, statements)
+	})
+
+	t.Run("Should point at the end of the statement", func(t *testing.T) {
+		t.Parallel()
+
+		sql := "SELECT 1;\nCREATE TABLE pgvet (\n"
+		_, syntaxErrors, err := parse(sql)
+		require.NoError(t, err)
+		require.Len(t, syntaxErrors, 1)
+		assert.Equal(t, "\nCREATE TABLE pgvet (\n", sql[syntaxErrors[0].start:syntaxErrors[0].end])
+	})
+
+	t.Run("Should leave out SQL that can't be split", func(t *testing.T) {
+		t.Parallel()
+
+		sql := "SELECT 1;\nSELECT 'unterminated;\n"
+		tree, syntaxErrors, err := parse(sql)
+		require.NoError(t, err)
+		assert.Empty(t, tree.GetStmts())
+		require.Len(t, syntaxErrors, 1)
+		assert.Equal(t, 0, syntaxErrors[0].start)
+		assert.Equal(t, len(sql), syntaxErrors[0].end)
+	})
+}
@@ -0,0 +1,91 @@
+package lint
+
+import (
+	"sort"
+
+	"github.com/onordander/pgvet/rules"
+)
+
+// sourceMap maps the offsets in SQL derived from a source back to the source, e.g. after substituting placeholders.
+type sourceMap struct {
+	// The text of the source.
+	source string
+	// The parts of the SQL copied from the source or inserted, ordered by offset.
+	parts []mappedPart
+}
+
+// mappedPart is a part of the SQL copied from the source, or inserted into the SQL at a location in the source.
+type mappedPart struct {
+	sql    int
+	source int
+	length int
+	// The SQL isn't in the source, e.g. a separator between the SQL of two calls. All of it is located at the source
+	// offset, up to but excluding the end so the part after it starts where it's copied from.
+	inserted bool
+}
+
+// add records that the SQL at sqlOffset is copied from the source at sourceOffset.
+func (m *sourceMap) add(sqlOffset, sourceOffset, length int) {
+	if length > 0 {
+		m.parts = append(m.parts, mappedPart{sql: sqlOffset, source: sourceOffset, length: length})
+	}
+}
+
+// insert records that the SQL at sqlOffset isn't in the source, it's located at sourceOffset.
+func (m *sourceMap) insert(sqlOffset, sourceOffset, length int) {
+	if length > 0 {
+		m.parts = append(m.parts, mappedPart{sql: sqlOffset, source: sourceOffset, length: length, inserted: true})
+	}
+}
+
+// part returns the index of the part that contains the offset or ends at it, false if the offset is in SQL that isn't
+// copied from the source or inserted. The index is then that of the next part.
+func (m *sourceMap) part(offset int32) (int, bool) {
+	i := sort.Search(len(m.parts), func(i int) bool {
+		end := m.parts[i].sql + m.parts[i].length
+		if m.parts[i].inserted {
+			return end > int(offset)
+		}
+		return end >= int(offset)
+	})
+	return i, i < len(m.parts) && m.parts[i].sql <= int(offset)
+}
+
+// offset returns the offset in the source of the offset in the SQL. The offsets in SQL that isn't copied from the
+// source, e.g. the value of a placeholder, are mapped to the end of what it replaced.
+func (m *sourceMap) offset(offset int32) int32 {
+	i, ok := m.part(offset)
+	switch {
+	case ok && m.parts[i].inserted:
+		return int32(m.parts[i].source)
+	case ok:
+		return int32(m.parts[i].source + int(offset) - m.parts[i].sql)
+	case i < len(m.parts):
+		return int32(m.parts[i].source)
+	default:
+		return int32(len(m.source))
+	}
+}
+
+// locate moves the locations of a result in the SQL to the source.
+func (m *sourceMap) locate(res rules.Result) rules.Result {
+	res.StmtStart, res.StmtEnd = m.offset(res.StmtStart), m.offset(res.StmtEnd)
+	res.Start, res.End = m.offset(res.Start), m.offset(res.End)
+	return res
+}
+
+// edits moves the edits of the SQL to the source, false if an edit changes SQL that isn't copied from the source.
+func (m *sourceMap) edits(edits []rules.Edit) ([]rules.Edit, bool) {
+	moved := make([]rules.Edit, 0, len(edits))
+	for _, edit := range edits {
+		start, ok := m.part(edit.Start)
+		if !ok || m.parts[start].inserted {
+			return nil, false
+		}
+		if end, ok := m.part(edit.End); !ok || end != start {
+			return nil, false
+		}
+		moved = append(moved, rules.Edit{Start: m.offset(edit.Start), End: m.offset(edit.End), Text: edit.Text})
+	}
+	return moved, true
+}
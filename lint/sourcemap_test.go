@@ -0,0 +1,80 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/onordander/pgvet/rules"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceMap(t *testing.T) {
+	t.Parallel()
+
+	source := "ALTER TABLE ${schema}.pgvet ADD COLUMN at ${type};"
+	sql, m := substitutePlaceholders(source, map[string]string{"schema": "app", "type": "timestamp"})
+	require.NotNil(t, m)
+	require.Equal(t, "ALTER TABLE app.pgvet ADD COLUMN at timestamp;", sql)
+
+	t.Run("Should map the offsets to the source", func(t *testing.T) {
+		t.Parallel()
+
+		cases := map[int32]int32{
+			// In the copied parts, including their ends
+			0:  0,
+			12: 12,
+			15: 21,
+			36: 42,
+			45: 49,
+			46: 50,
+			// In the values of the placeholders, mapped to the end of the placeholder
+			13: 21,
+			40: 49,
+		}
+		for offset, expected := range cases {
+			assert.Equal(t, expected, m.offset(offset), "offset %d", offset)
+		}
+	})
+
+	t.Run("Should only move the edits of copied SQL", func(t *testing.T) {
+		t.Parallel()
+
+		edits, ok := m.edits([]rules.Edit{{Start: 12, End: 12, Text: "IF EXISTS "}, {Start: 21, End: 21, Text: "x"}})
+		require.True(t, ok)
+		assert.Equal(t, []rules.Edit{{Start: 12, End: 12, Text: "IF EXISTS "}, {Start: 27, End: 27, Text: "x"}}, edits)
+
+		// Replacing the value of a placeholder
+		_, ok = m.edits([]rules.Edit{{Start: 36, End: 45, Text: "timestamptz"}})
+		assert.False(t, ok)
+
+		// Replacing across a placeholder
+		_, ok = m.edits([]rules.Edit{{Start: 6, End: 21, Text: "TABLE other"}})
+		assert.False(t, ok)
+	})
+
+	t.Run("Should locate inserted SQL at its location", func(t *testing.T) {
+		t.Parallel()
+
+		// SELECT 1 extracted from db.Exec("SELECT 1") followed by a separator
+		m := &sourceMap{source: `db.Exec("SELECT 1")`}
+		m.add(0, 9, 8)
+		m.insert(8, 17, 2)
+
+		cases := map[int32]int32{0: 9, 8: 17, 9: 17, 10: 19}
+		for offset, expected := range cases {
+			assert.Equal(t, expected, m.offset(offset), "offset %d", offset)
+		}
+
+		_, ok := m.edits([]rules.Edit{{Start: 8, End: 10, Text: ";"}})
+		assert.False(t, ok)
+	})
+
+	t.Run("Should not map SQL without placeholders", func(t *testing.T) {
+		t.Parallel()
+
+		sql, m := substitutePlaceholders("SELECT '${unknown}';", map[string]string{"schema": "app"})
+		assert.Equal(t, "SELECT '${unknown}';", sql)
+		assert.Nil(t, m)
+	})
+}
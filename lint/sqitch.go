@@ -0,0 +1,150 @@
+package lint
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// sqitchPlanFile is the name of the file sqitch reads the plan of a project from.
+const sqitchPlanFile = "sqitch.plan"
+
+// The directories of a sqitch project the scripts of the changes are in.
+const (
+	sqitchDeploy = "deploy"
+	sqitchRevert = "revert"
+	sqitchVerify = "verify"
+)
+
+// sqitchScript is a source that is a script of a sqitch change.
+type sqitchScript struct {
+	index int
+	// The directory of the project, the parent of the deploy, revert and verify directories.
+	project string
+	kind    string
+	// The name of the change, e.g. users or users@v1.0 for a change that has been reworked since the tag.
+	change string
+}
+
+// sqitchMigrations lints the deploy scripts of sqitch changes in the order of the plan, each followed by its revert
+// script if the down migrations are enabled. The verify scripts aren't linted. Sqitch doesn't wrap the scripts in a
+// transaction, they begin and commit their own. Files that aren't scripts of a change in the plan are linted as plain
+// SQL after the others.
+func (l *Linter) sqitchMigrations(sources []Source) ([]migration, error) {
+	var scripts []sqitchScript
+	var plain []int
+	for i, src := range sources {
+		script, ok := parseSqitchScript(src.Name)
+		if !ok {
+			plain = append(plain, i)
+			continue
+		}
+		script.index = i
+		scripts = append(scripts, script)
+	}
+
+	// The positions of the changes in the plans of the projects, in the order the projects were first seen
+	var projects []string
+	plans := map[string]map[string]int{}
+	for _, script := range scripts {
+		if _, ok := plans[script.project]; ok {
+			continue
+		}
+		planPath := l.cfg.Sqitch.Plan
+		if planPath == "" {
+			planPath = filepath.Join(script.project, sqitchPlanFile)
+		}
+		content, err := os.ReadFile(planPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the sqitch plan: %w", err)
+		}
+		positions := map[string]int{}
+		for i, change := range parseSqitchPlan(string(content)) {
+			positions[change] = i
+		}
+		projects = append(projects, script.project)
+		plans[script.project] = positions
+	}
+
+	scripts = slices.DeleteFunc(scripts, func(script sqitchScript) bool {
+		if _, ok := plans[script.project][script.change]; !ok {
+			plain = append(plain, script.index)
+			return true
+		}
+		return script.kind == sqitchVerify || (script.kind == sqitchRevert && !l.cfg.downEnabled(false))
+	})
+	slices.SortStableFunc(scripts, func(a, b sqitchScript) int {
+		return cmp.Or(
+			cmp.Compare(slices.Index(projects, a.project), slices.Index(projects, b.project)),
+			cmp.Compare(plans[a.project][a.change], plans[b.project][b.change]),
+			// The revert script comes after the deploy script it reverts
+			compareBool(a.kind == sqitchRevert, b.kind == sqitchRevert),
+		)
+	})
+	slices.Sort(plain)
+
+	var migrations []migration
+	for _, script := range scripts {
+		migrations = append(migrations, migration{
+			Source: sources[script.index],
+			source: script.index,
+			down:   script.kind == sqitchRevert,
+		})
+	}
+	for _, index := range plain {
+		migrations = append(migrations, l.plainMigration(sources[index], index))
+	}
+	return migrations, nil
+}
+
+// parseSqitchScript returns the project, kind and change of a script from its path, e.g. db/deploy/users.sql. False if
+// the path isn't in a deploy, revert or verify directory.
+func parseSqitchScript(name string) (sqitchScript, bool) {
+	if !strings.HasSuffix(name, ".sql") {
+		return sqitchScript{}, false
+	}
+	parts := strings.Split(filepath.ToSlash(name), "/")
+	// The changes can be in subdirectories of the script directories, e.g. deploy/schema/users.sql
+	for i := len(parts) - 2; i >= 0; i-- {
+		switch parts[i] {
+		case sqitchDeploy, sqitchRevert, sqitchVerify:
+			project := "."
+			if i > 0 {
+				project = cmp.Or(strings.Join(parts[:i], "/"), "/")
+			}
+			return sqitchScript{
+				project: filepath.FromSlash(project),
+				kind:    parts[i],
+				change:  strings.TrimSuffix(strings.Join(parts[i+1:], "/"), ".sql"),
+			}, true
+		}
+	}
+	return sqitchScript{}, false
+}
+
+// parseSqitchPlan returns the changes of the plan in deploy order. A change that has been reworked is in the plan once
+// for every version, the earlier versions are named after the tag they were reworked at, e.g. users@v1.0.
+func parseSqitchPlan(plan string) []string {
+	var changes []string
+	var tag string
+	for line := range strings.Lines(plan) {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "%") {
+			continue
+		}
+		if name, ok := strings.CutPrefix(fields[0], "@"); ok {
+			tag = name
+			continue
+		}
+
+		change := strings.TrimPrefix(fields[0], "+")
+		if i := slices.Index(changes, change); i >= 0 {
+			changes[i] = change + "@" + tag
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
@@ -0,0 +1,122 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onordander/pgvet/rules"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sqitchPlan = `%syntax-version=1.0.0
+%project=pgvet
+
+schema 2024-01-01T00:00:00Z Jane Doe <jane@example.com> # Adds the schema
+users [schema] 2024-01-02T00:00:00Z Jane Doe <jane@example.com> # Adds the users
+@v1.0 2024-01-03T00:00:00Z Jane Doe <jane@example.com> # Tags the release
+
+users [users@v1.0] 2024-01-04T00:00:00Z Jane Doe <jane@example.com> # Reworks the users
+`
+
+func TestParseSqitchPlan(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"schema", "users@v1.0", "users"}, parseSqitchPlan(sqitchPlan))
+}
+
+func TestParseSqitchScript(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]sqitchScript{
+		"deploy/users.sql":            {project: ".", kind: "deploy", change: "users"},
+		"db/revert/users@v1.0.sql":    {project: "db", kind: "revert", change: "users@v1.0"},
+		"/db/deploy/schema/users.sql": {project: "/db", kind: "deploy", change: "schema/users"},
+	}
+	for name, expected := range cases {
+		script, ok := parseSqitchScript(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, expected, script, name)
+	}
+
+	for _, name := range []string{"users.sql", "deploy.sql", "deploy/users.txt"} {
+		_, ok := parseSqitchScript(name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestLintSqitch(t *testing.T) {
+	t.Parallel()
+
+	project := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(project, sqitchPlanFile), []byte(sqitchPlan), 0o644))
+
+	cfg := DefaultConfig()
+	cfg.MigrationTool = MigrationToolSqitch
+
+	sources := []Source{
+		{Name: filepath.Join(project, "deploy", "schema.sql"), SQL: "CREATE TABLE app.pgvet (id int);\nCREATE INDEX CONCURRENTLY IF NOT EXISTS pgvet_idx ON app.pgvet(id);\n"},
+		{Name: filepath.Join(project, "deploy", "users.sql"), SQL: "BEGIN;\nALTER TABLE app.users DROP COLUMN IF EXISTS name;\nCOMMIT;\n"},
+		{Name: filepath.Join(project, "deploy", "users@v1.0.sql"), SQL: "CREATE TABLE IF NOT EXISTS app.users (id int, name text);\n"},
+		{Name: filepath.Join(project, "revert", "users.sql"), SQL: "DROP INDEX app.users_idx;\n"},
+		{Name: filepath.Join(project, "verify", "users.sql"), SQL: "DROP INDEX app.users_idx;\n"},
+		{Name: filepath.Join(project, "deploy", "orphan.sql"), SQL: "DROP TABLE IF EXISTS app.users;\n"},
+	}
+
+	type violation struct {
+		File string
+		Code rules.Code
+	}
+	violations := func(r Report) []violation {
+		var violations []violation
+		for _, v := range r {
+			violations = append(violations, violation{File: v.File, Code: v.Code})
+		}
+		return violations
+	}
+
+	t.Run("Should lint the deploy scripts in the order of the plan", func(t *testing.T) {
+		t.Parallel()
+
+		// Sqitch doesn't wrap the scripts in a transaction, so creating the index concurrently is fine
+		report, err := New(cfg).Lint(sources)
+		require.NoError(t, err)
+		assert.Equal(t, []violation{
+			{File: sources[0].Name, Code: "missing-if-not-exists"},
+			{File: sources[1].Name, Code: "drop-column"},
+			// Not in the plan
+			{File: sources[5].Name, Code: "drop-table"},
+		}, violations(report))
+	})
+
+	t.Run("Should lint the revert scripts if enabled", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := cfg
+		enabled := true
+		cfg.Down.Enabled = &enabled
+
+		// The verify script isn't linted
+		report, err := New(cfg).Lint(sources)
+		require.NoError(t, err)
+		assert.Equal(t, []violation{
+			{File: sources[0].Name, Code: "missing-if-not-exists"},
+			{File: sources[1].Name, Code: "drop-column"},
+			{File: sources[3].Name, Code: "non-concurrent-index"},
+			{File: sources[3].Name, Code: "missing-if-exists"},
+			{File: sources[5].Name, Code: "drop-table"},
+		}, violations(report))
+	})
+
+	t.Run("Should read the configured plan", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := cfg
+		cfg.Sqitch.Plan = filepath.Join(project, "missing.plan")
+
+		_, err := New(cfg).Lint(sources)
+		assert.ErrorContains(t, err, "failed to read the sqitch plan")
+	})
+}
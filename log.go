@@ -18,6 +18,11 @@ func (l *logger) Info(format string, args ...any) {
 	l.Printf(format, args...)
 }
 
+func (l *logger) Warn(format string, args ...any) {
+	msg := fmt.Sprintf("\033[0;33m%s\033[0m\n", format)
+	l.Printf(msg, args...)
+}
+
 func (l *logger) Error(format string, args ...any) {
 	msg := fmt.Sprintf("\033[0;31m%s\033[0m\n", format)
 	l.Printf(msg, args...)
@@ -1,20 +1,20 @@
 package main
 
 import (
-	"cmp"
 	_ "embed"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"maps"
 	"os"
 	"path/filepath"
+	"runtime"
 	"slices"
 	"strings"
 
+	"github.com/onordander/pgvet/lint"
 	"github.com/onordander/pgvet/rules"
-
-	pgquery "github.com/wasilibs/go-pgquery"
 )
 
 //go:embed NOTICE.txt
@@ -24,8 +24,20 @@ var notice string
 var version string
 
 const (
-	formatJson = "json"
-	formatText = "text"
+	formatCheckstyle = "checkstyle"
+	formatGithub     = "github"
+	formatGitlab     = "gitlab"
+	formatJson       = "json"
+	formatJunit      = "junit"
+	formatSarif      = "sarif"
+	formatText       = "text"
+)
+
+const (
+	// The pattern that reads a migration from stdin.
+	stdinPattern = "-"
+	// The name of the migration read from stdin in the report unless --stdin-filename is set.
+	defaultStdinFilename = "<stdin>"
 )
 
 func main() {
@@ -33,12 +45,20 @@ func main() {
 
 	flagSet := flag.NewFlagSet("lint", flag.ExitOnError)
 	flagSet.SetOutput(wErr)
-	format := flagSet.String("format", "text", "Set output format, text or json. Text is default")
+	format := flagSet.String("format", "text", "Set output format, text, json, sarif, junit, github, gitlab or checkstyle. Text is default")
 	exitStatusOnViolations := flagSet.Bool("exit-status-on-violation", false, "Set exit status >0 if any violations are found")
+	failOn := flagSet.String("fail-on", "", "Set exit status >0 if any violations with at least this severity are found: error, warning or info")
 	config := flagSet.String("config", "", "Config file")
+	pgVersion := flagSet.Int("pg-version", 0, "Major version of the PostgreSQL server the migrations are deployed to, overrides the config file")
+	jobs := flagSet.Int("jobs", runtime.GOMAXPROCS(0), "Number of files to parse concurrently")
+	diff := flagSet.Bool("diff", false, "fix: print a unified diff of the fixes instead of rewriting the files")
+	baseline := flagSet.String("baseline", "", "lint: baseline file of accepted violations, they are left out of the report")
+	writeBaseline := flagSet.String("write-baseline", "", "lint: write the violations found to a baseline file instead of reporting them")
+	stdinFilename := flagSet.String("stdin-filename", "", "lint: name of the migration read from stdin with -, used in the report")
 	flagSet.Usage = func() {
 		fmt.Fprint(wErr, "Usage:\n")
-		fmt.Fprint(wErr, "\t./pgvet lint [--config <config.yaml>] <filepattern>...\n")
+		fmt.Fprint(wErr, "\t./pgvet lint [--config <config.yaml>] [--fail-on <severity>] [--pg-version <version>] [--jobs <n>] [--baseline <file>] [--write-baseline <file>] [--stdin-filename <name>] <filepattern | ->...\n")
+		fmt.Fprint(wErr, "\t./pgvet fix [--config <config.yaml>] [--diff] [--pg-version <version>] [--jobs <n>] <filepattern>...\n")
 		fmt.Fprint(wErr, "\t./pgvet --help\n")
 		fmt.Fprint(wErr, "\t./pgvet rules\n")
 		fmt.Fprint(wErr, "\t./pgvet version\n")
@@ -46,6 +66,8 @@ func main() {
 		flagSet.PrintDefaults()
 		fmt.Fprint(wErr, "Example:\n")
 		fmt.Fprint(wErr, "\t./pgvet lint --config=config.yaml migrations/*.sql\n")
+		fmt.Fprint(wErr, "\t./pgvet fix --diff migrations/*.sql\n")
+		fmt.Fprint(wErr, "\tatlas migrate diff --dry-run | ./pgvet lint --stdin-filename migrations/new.sql -\n")
 	}
 
 	if len(os.Args) < 2 {
@@ -70,7 +92,8 @@ func main() {
 			fmt.Fprintf(wOut, "\t| %s%s%s\n", bold, rule.Slug, normal)
 			fmt.Fprintf(wOut, "\tHelp: %s\n", rule.Help)
 			fmt.Fprintf(wOut, "\tEnabled by default: %s\n", enabled)
-			fmt.Fprintf(wOut, "\tExplanation: https://github.com/ONordander/pgvet?tab=readme-ov-file#%s\n", rule.Code)
+			fmt.Fprintf(wOut, "\tSeverity: %s\n", rule.Severity)
+			fmt.Fprintf(wOut, "\tExplanation: %s\n", explanationURL(rule.Code))
 			fmt.Fprintf(wOut, "\tCategory: %s\n\n", rule.Category)
 		}
 	case "lint":
@@ -85,121 +108,139 @@ func main() {
 			configpath = config
 		}
 
+		failOnSeverity := rules.Severity(*failOn)
+		if failOnSeverity == "" && *exitStatusOnViolations {
+			failOnSeverity = rules.SeverityInfo
+		}
+
 		// Multi args to allow usage where the shell expands wildcards like: ./pgvet migrations/*.sql
 		patterns := flagSet.Args()[0:]
 
-		os.Exit(lint(wOut, wErr, patterns, configpath, *format, *exitStatusOnViolations))
+		os.Exit(runLint(wOut, wErr, patterns, options{
+			configpath: configpath,
+			format:     *format,
+			failOn:     failOnSeverity,
+			pgVersion:  *pgVersion,
+			jobs:       *jobs,
+			// Set by GitHub Actions when running in a workflow
+			githubStepSummary: os.Getenv("GITHUB_STEP_SUMMARY"),
+			stdin:             os.Stdin,
+			stdinFilename:     *stdinFilename,
+			baseline:          *baseline,
+			writeBaseline:     *writeBaseline,
+		}))
+	case "fix":
+		_ = flagSet.Parse(os.Args[2:])
+		if flagSet.NArg() < 1 {
+			flagSet.Usage()
+			os.Exit(2)
+		}
+
+		var configpath *string
+		if *config != "" {
+			configpath = config
+		}
+
+		os.Exit(runFix(wOut, wErr, flagSet.Args(), options{
+			configpath: configpath,
+			pgVersion:  *pgVersion,
+			jobs:       *jobs,
+			diff:       *diff,
+		}))
 	default:
 		flagSet.Usage()
 		os.Exit(2)
 	}
 }
 
-func lint(
-	wOut, wErr io.Writer,
-	patterns []string,
-	configpath *string,
-	format string,
-	exitStatusOnViolations bool,
-) int {
+// options are the command line flags of the lint and fix commands.
+type options struct {
+	configpath *string
+	format     string
+	failOn     rules.Severity
+	pgVersion  int
+	// The number of files parsed concurrently, GOMAXPROCS if zero.
+	jobs int
+	// fix only: print a diff instead of rewriting the files.
+	diff bool
+	// The file to append a Markdown job summary to with the github format.
+	githubStepSummary string
+	// lint only: the migration is read from stdin with the - pattern and named stdinFilename in the report.
+	stdin         io.Reader
+	stdinFilename string
+	// lint only: the baseline file to leave the accepted violations out with, and the one to write the violations to.
+	baseline      string
+	writeBaseline string
+}
+
+func runLint(wOut, wErr io.Writer, patterns []string, opts options) int {
 	log := newLogger(wErr)
 
-	switch format {
-	case formatJson, formatText:
+	switch opts.format {
+	case formatCheckstyle, formatGithub, formatGitlab, formatJson, formatJunit, formatSarif, formatText:
 	default:
-		log.Error("Unknown format %q", format)
+		log.Error("Unknown format %q", opts.format)
 		return 1
 	}
 
-	cfg := defaultConfig()
-	if configpath != nil {
+	if opts.failOn != "" && !opts.failOn.Valid() {
+		log.Error("Unknown severity %q", opts.failOn)
+		return 1
+	}
+
+	linter, ok := newLinter(log, opts)
+	if !ok {
+		return 1
+	}
+
+	var baseline lint.Baseline
+	if opts.baseline != "" {
 		var err error
-		cfg, err = overlayConfig(cfg, *configpath)
+		baseline, err = lint.LoadBaseline(opts.baseline)
 		if err != nil {
-			log.Error("Failed to parse config: %s", err.Error())
+			log.Error("Failed to read baseline: %s", err.Error())
 			return 1
 		}
 	}
 
-	fileMap := map[string]struct{}{}
-	for _, pattern := range patterns {
-		fileInfo, err := os.Stat(pattern)
-		// If pattern is a directory be nice and parse all the files
-		if err == nil && fileInfo.IsDir() {
-			pattern = filepath.Join(pattern, "*")
-		}
-		patternFiles, err := filepath.Glob(pattern)
-		if err != nil {
-			log.Error(err.Error())
-			return 1
-		}
-		for _, f := range patternFiles {
-			i, err := os.Stat(f)
-			if err != nil {
-				continue
-			}
-			if !i.IsDir() {
-				fileMap[f] = struct{}{}
-			}
-		}
+	sources, ok := readSources(log, patterns, opts)
+	if !ok {
+		return 1
 	}
+	log.Info("Linting %d file(s)...\n\n", len(sources))
 
-	if len(fileMap) == 0 {
-		log.Error("No files found for patterns: %v", patterns)
+	report, err := linter.Lint(sources)
+	if err != nil {
+		logLintError(log, err)
 		return 1
 	}
-	log.Info("Linting %d file(s)...\n\n", len(fileMap))
 
-	var report Report
-	for _, f := range slices.Sorted(maps.Keys(fileMap)) {
-		content, err := os.ReadFile(f)
-		if err != nil {
-			log.Error("Failed to read file %s", err.Error())
+	if opts.writeBaseline != "" {
+		if err := lint.NewBaseline(report).Write(opts.writeBaseline); err != nil {
+			log.Error("Failed to write baseline: %s", err.Error())
 			return 1
 		}
+		log.Info("Wrote %d violation(s) to baseline %s\n", len(report), opts.writeBaseline)
+		return 0
+	}
 
-		query := string(content)
+	var files []string
+	for _, src := range sources {
+		files = append(files, src.Name)
+	}
 
-		tree, err := pgquery.Parse(query)
-		if err != nil {
-			log.Error("Failed to parse SQL from file %q: %s", f, err.Error())
-			return 1
+	if opts.baseline != "" {
+		var stale []lint.BaselineEntry
+		report, stale = baseline.Filter(report, files)
+		for _, entry := range stale {
+			log.Warn("Stale baseline entry, the violation is gone: %s %s %s", entry.File, entry.Code, entry.Message)
 		}
-
-		var results []rules.Result
-		for _, rule := range rules.AllRules() {
-			if cfg, ok := cfg.Rules[rule.Code]; !ok || !cfg.Enabled {
-				continue
-			}
-			partial, err := rule.Fn(tree, rule.Code, rule.Slug, rule.Help, *cfg.ImplicitTransaction)
-			if err != nil {
-				log.Error("Rule %q failed on file %q: %s", rule.Code, f, err.Error())
-				return 1
-			}
-			results = append(results, partial...)
-		}
-
-		slices.SortFunc(results, func(a, b rules.Result) int {
-			return cmp.Compare(a.StmtStart, b.StmtStart)
-		})
-
-		filtered := filterNoLints(query, results)
-		for _, res := range filtered {
-			statementLine := countLines(query[:res.StmtStart], query[res.StmtStart:res.StmtEnd])
-			stmt := strings.TrimSpace(query[res.StmtStart:res.StmtEnd])
-			entry := violation{
-				File:          f,
-				Code:          res.Code,
-				Statement:     stmt,
-				StatementLine: statementLine,
-				Slug:          res.Slug,
-				Help:          res.Help,
-			}
-			report = append(report, entry)
+		if len(stale) > 0 {
+			log.Warn("Prune %d stale baseline entry(ies) with --write-baseline=%s", len(stale), opts.baseline)
 		}
 	}
-
-	serialized, err := report.Serialize(format)
+	run := lintRun{report: report, files: files, rules: linter.EnabledRules()}
+	serialized, err := serialize(run, opts.format)
 	if err != nil {
 		log.Error("Failed to seralize report: %s", err.Error())
 		return 1
@@ -207,24 +248,138 @@ func lint(
 
 	fmt.Fprint(wOut, serialized)
 
-	if len(report) > 0 && exitStatusOnViolations {
-		return 1
+	if opts.format == formatGithub && opts.githubStepSummary != "" {
+		if err := appendGithubSummary(opts.githubStepSummary, run); err != nil {
+			log.Error("Failed to write job summary: %s", err.Error())
+			return 1
+		}
+	}
+
+	for _, v := range report {
+		// SQL that can't be parsed fails the run regardless of the severity, the migration would fail too
+		if v.Code == rules.ParseErrorCode {
+			return 1
+		}
+		if opts.failOn != "" && v.Severity.AtLeast(opts.failOn) {
+			return 1
+		}
 	}
 	return 0
 }
 
-func countLines(precedingContent string, content string) int {
-	precedingNumLines := len(strings.Split(strings.ReplaceAll(precedingContent, "\r\n", "\n"), "\n"))
+// newLinter returns a linter with the config and flags in opts. Errors are logged.
+func newLinter(log logger, opts options) (*lint.Linter, bool) {
+	if opts.jobs < 0 {
+		log.Error("Invalid number of jobs %d", opts.jobs)
+		return nil, false
+	}
+
+	cfg, ok := loadConfig(log, opts.configpath, opts.pgVersion)
+	if !ok {
+		return nil, false
+	}
+
+	linter := lint.New(cfg)
+	linter.Jobs = opts.jobs
+	return linter, true
+}
 
-	// The statement can contain newlines too which will be trimmed, so count them now
-	var numLines int
-	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
-		if line == "" {
-			numLines += 1
+// readSources reads the files matching the patterns in lexical order. The pattern - reads a migration from stdin, which
+// is linted after the files. Errors are logged.
+func readSources(log logger, patterns []string, opts options) ([]lint.Source, bool) {
+	var filePatterns []string
+	var fromStdin bool
+	for _, pattern := range patterns {
+		if pattern == stdinPattern {
+			fromStdin = true
 			continue
 		}
-		break
+		filePatterns = append(filePatterns, pattern)
+	}
+
+	files, err := findFiles(filePatterns)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, false
+	}
+	if len(files) == 0 && !fromStdin {
+		log.Error("No files found for patterns: %v", patterns)
+		return nil, false
+	}
+
+	sources, err := lint.ReadFiles(files)
+	if err != nil {
+		log.Error("Failed to read file %s", err.Error())
+		return nil, false
+	}
+
+	if fromStdin {
+		sql, err := io.ReadAll(opts.stdin)
+		if err != nil {
+			log.Error("Failed to read stdin: %s", err.Error())
+			return nil, false
+		}
+		name := opts.stdinFilename
+		if name == "" {
+			name = defaultStdinFilename
+		}
+		sources = append(sources, lint.Source{Name: name, SQL: string(sql)})
+	}
+	return sources, true
+}
+
+// loadConfig loads the config file, if any, and applies the PostgreSQL version flag. Errors are logged.
+func loadConfig(log logger, configpath *string, pgVersion int) (lint.Config, bool) {
+	cfg := lint.DefaultConfig()
+	if configpath != nil {
+		var err error
+		cfg, err = lint.LoadConfig(*configpath)
+		if err != nil {
+			log.Error("Failed to parse config: %s", err.Error())
+			return lint.Config{}, false
+		}
+	}
+
+	if pgVersion != 0 {
+		if err := cfg.SetPostgresVersion(pgVersion); err != nil {
+			log.Error(err.Error())
+			return lint.Config{}, false
+		}
+	}
+	return cfg, true
+}
+
+func logLintError(log logger, err error) {
+	var parseErr *lint.ParseError
+	if errors.As(err, &parseErr) {
+		log.Error("Failed to parse SQL from file %q: %s", parseErr.File, parseErr.Err.Error())
+		return
 	}
+	log.Error("Failed to lint: %s", err.Error())
+}
 
-	return precedingNumLines + numLines
+// findFiles returns the files matching the patterns in lexical order.
+func findFiles(patterns []string) ([]string, error) {
+	fileMap := map[string]struct{}{}
+	for _, pattern := range patterns {
+		fileInfo, err := os.Stat(pattern)
+		// If pattern is a directory be nice and parse all the files
+		if err == nil && fileInfo.IsDir() {
+			pattern = filepath.Join(pattern, "*")
+		}
+		patternFiles, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range patternFiles {
+			i, err := os.Stat(f)
+			if err != nil {
+				continue
+			}
+			if !i.IsDir() {
+				fileMap[f] = struct{}{}
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(fileMap)), nil
 }
@@ -1,13 +1,76 @@
 package main
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 func BenchmarkLint(b *testing.B) {
 	var writer noOpWriter
 	for b.Loop() {
-		lint(writer, writer, []string{"testdata/benchmark/*.sql"}, ptr("testdata/config-all-enabled.yaml"), formatText, false)
+		runLint(writer, writer, []string{"testdata/benchmark/*.sql"}, options{configpath: ptr("testdata/config-all-enabled.yaml"), format: formatText})
+	}
+}
+
+// BenchmarkLintJobs lints a migration history of many files with a single job and with GOMAXPROCS jobs to measure the
+// speed-up of linting concurrently.
+func BenchmarkLintJobs(b *testing.B) {
+	const numFiles = 64
+
+	content, err := os.ReadFile("testdata/benchmark/1.sql")
+	require.NoError(b, err)
+
+	dir := b.TempDir()
+	for i := range numFiles {
+		path := filepath.Join(dir, fmt.Sprintf("%03d.sql", i))
+		require.NoError(b, os.WriteFile(path, content, 0o600))
+	}
+
+	lintJobs := func(jobs int) {
+		var writer noOpWriter
+		runLint(writer, writer, []string{dir}, options{
+			configpath: ptr("testdata/config-all-enabled.yaml"),
+			format:     formatText,
+			jobs:       jobs,
+		})
+	}
+	for _, jobs := range slices.Compact([]int{1, runtime.GOMAXPROCS(0)}) {
+		b.Run(fmt.Sprintf("jobs=%d", jobs), func(b *testing.B) {
+			// The parser instances are created on first use, keep them out of the measurements
+			lintJobs(jobs)
+			for b.Loop() {
+				lintJobs(jobs)
+			}
+		})
+	}
+}
+
+// BenchmarkLintHistory lints a long migration history where every file creates a table, so the cost of carrying the
+// schema from file to file shows.
+func BenchmarkLintHistory(b *testing.B) {
+	const numFiles = 1000
+
+	dir := b.TempDir()
+	for i := range numFiles {
+		path := filepath.Join(dir, fmt.Sprintf("%04d.sql", i))
+		sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS pgvet_%d (id bigint PRIMARY KEY, value text);\n", i)
+		require.NoError(b, os.WriteFile(path, []byte(sql), 0o600))
+	}
+
+	lint := func() {
+		var writer noOpWriter
+		runLint(writer, writer, []string{dir}, options{configpath: ptr("testdata/config-all-enabled.yaml"), format: formatJson})
+	}
+	// The parser instances are created on first use, keep them out of the measurements
+	lint()
+	for b.Loop() {
+		lint()
 	}
 }
 
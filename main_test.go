@@ -3,10 +3,15 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"encoding/xml"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/onordander/pgvet/lint"
+	"github.com/onordander/pgvet/rules"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -36,7 +41,7 @@ func TestLint(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 			var wOut, wErr strings.Builder
-			rc := lint(&wOut, &wErr, []string{tc.file}, tc.configfile, formatText, false)
+			rc := runLint(&wOut, &wErr, []string{tc.file}, options{configpath: tc.configfile, format: formatText})
 			require.Zero(t, rc, wErr.String())
 
 			if shouldWriteTestdata {
@@ -56,7 +61,7 @@ func TestLintPatterns(t *testing.T) {
 	t.Run("Wildcard", func(t *testing.T) {
 		t.Parallel()
 		var wOut, wErr strings.Builder
-		rc := lint(&wOut, &wErr, []string{"testdata/patterns/*"}, nil, formatText, false)
+		rc := runLint(&wOut, &wErr, []string{"testdata/patterns/*"}, options{format: formatText})
 		require.Zero(t, rc, wErr.String())
 
 		out := wOut.String()
@@ -68,7 +73,7 @@ func TestLintPatterns(t *testing.T) {
 	t.Run("Folder", func(t *testing.T) {
 		t.Parallel()
 		var wOut, wErr strings.Builder
-		rc := lint(&wOut, &wErr, []string{"testdata/patterns"}, nil, formatText, false)
+		rc := runLint(&wOut, &wErr, []string{"testdata/patterns"}, options{format: formatText})
 		require.Zero(t, rc, wErr.String())
 
 		out := wOut.String()
@@ -80,7 +85,7 @@ func TestLintPatterns(t *testing.T) {
 	t.Run("Pattern", func(t *testing.T) {
 		t.Parallel()
 		var wOut, wErr strings.Builder
-		rc := lint(&wOut, &wErr, []string{"testdata/patterns/*-pattern.sql"}, nil, formatText, false)
+		rc := runLint(&wOut, &wErr, []string{"testdata/patterns/*-pattern.sql"}, options{format: formatText})
 		require.Zero(t, rc, wErr.String())
 
 		out := wOut.String()
@@ -92,7 +97,7 @@ func TestLintPatterns(t *testing.T) {
 	t.Run("Multiple patterns", func(t *testing.T) {
 		t.Parallel()
 		var wOut, wErr strings.Builder
-		rc := lint(&wOut, &wErr, []string{"testdata/**/1-pattern.sql", "testdata/**/2-pattern.sql"}, nil, formatText, false)
+		rc := runLint(&wOut, &wErr, []string{"testdata/**/1-pattern.sql", "testdata/**/2-pattern.sql"}, options{format: formatText})
 		require.Zero(t, rc, wErr.String())
 
 		out := wOut.String()
@@ -102,19 +107,313 @@ func TestLintPatterns(t *testing.T) {
 	})
 }
 
+func TestLintStdin(t *testing.T) {
+	t.Parallel()
+
+	sql := "ALTER TABLE pgvet DROP COLUMN IF EXISTS value;\n\n-- pgvet_nolint:drop-table\nDROP TABLE IF EXISTS pgvet;\n"
+
+	t.Run("Should name the migration", func(t *testing.T) {
+		t.Parallel()
+
+		var wOut, wErr strings.Builder
+		opts := options{format: formatJson, stdin: strings.NewReader(sql), stdinFilename: "migrations/001.sql"}
+		rc := runLint(&wOut, &wErr, []string{"-"}, opts)
+		require.Zero(t, rc, wErr.String())
+
+		var report lint.Report
+		require.NoError(t, json.Unmarshal([]byte(wOut.String()), &report))
+		// The nolint directive in the migration is respected
+		require.Len(t, report, 1)
+		assert.Equal(t, "migrations/001.sql", report[0].File)
+		assert.Equal(t, rules.Code("drop-column"), report[0].Code)
+		assert.Equal(t, 1, report[0].StartLine)
+	})
+
+	t.Run("Should default the name", func(t *testing.T) {
+		t.Parallel()
+
+		var wOut, wErr strings.Builder
+		rc := runLint(&wOut, &wErr, []string{"-"}, options{format: formatGithub, stdin: strings.NewReader(sql)})
+		require.Zero(t, rc, wErr.String())
+		assert.Contains(t, wOut.String(), "::error file=<stdin>,line=1,")
+	})
+
+	t.Run("Should lint stdin after the files", func(t *testing.T) {
+		t.Parallel()
+
+		var wOut, wErr strings.Builder
+		opts := options{format: formatCheckstyle, stdin: strings.NewReader(sql), stdinFilename: "new.sql"}
+		rc := runLint(&wOut, &wErr, []string{"-", "testdata/noerrors.sql"}, opts)
+		require.Zero(t, rc, wErr.String())
+
+		var report checkstyleReport
+		require.NoError(t, xml.Unmarshal([]byte(wOut.String()), &report))
+		require.Len(t, report.Files, 2)
+		assert.Equal(t, "testdata/noerrors.sql", report.Files[0].Name)
+		assert.Equal(t, "new.sql", report.Files[1].Name)
+		assert.Len(t, report.Files[1].Errors, 1)
+	})
+}
+
+func TestLintBaseline(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	migration := filepath.Join(dir, "001.sql")
+	mustWriteFile(t, "ALTER TABLE pgvet DROP COLUMN IF EXISTS value;\n", migration)
+	baseline := filepath.Join(dir, "baseline.json")
+
+	var wOut, wErr strings.Builder
+	rc := runLint(&wOut, &wErr, []string{migration}, options{format: formatText, failOn: rules.SeverityError, writeBaseline: baseline})
+	require.Zero(t, rc, wErr.String())
+	assert.Empty(t, wOut.String())
+	assert.Contains(t, wErr.String(), "Wrote 1 violation(s) to baseline")
+
+	// The accepted violation is left out
+	wOut.Reset()
+	wErr.Reset()
+	rc = runLint(&wOut, &wErr, []string{migration}, options{format: formatText, failOn: rules.SeverityError, baseline: baseline})
+	require.Zero(t, rc, wErr.String())
+	assert.Contains(t, wOut.String(), "0 violations found")
+
+	// New violations are reported and the fixed one is stale
+	mustWriteFile(t, "DROP TABLE IF EXISTS pgvet;\n", migration)
+	wOut.Reset()
+	wErr.Reset()
+	rc = runLint(&wOut, &wErr, []string{migration}, options{format: formatText, failOn: rules.SeverityError, baseline: baseline})
+	require.NotZero(t, rc)
+	assert.Contains(t, wOut.String(), "drop-table")
+	assert.NotContains(t, wOut.String(), "drop-column")
+	assert.Contains(t, wErr.String(), "Stale baseline entry, the violation is gone: "+migration+" drop-column Dropping column public.pgvet.value")
+}
+
+func TestLintCatalog(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Index created in a later file", func(t *testing.T) {
+		t.Parallel()
+
+		var wOut, wErr strings.Builder
+		rc := runLint(&wOut, &wErr, []string{"testdata/catalog"}, options{configpath: ptr("testdata/config-all-enabled.yaml"), format: formatText})
+		require.Zero(t, rc, wErr.String())
+		assert.NotContains(t, wOut.String(), "missing-foreign-key-index")
+	})
+
+	t.Run("Index file not linted", func(t *testing.T) {
+		t.Parallel()
+
+		var wOut, wErr strings.Builder
+		rc := runLint(&wOut, &wErr, []string{"testdata/catalog/1.sql"}, options{configpath: ptr("testdata/config-all-enabled.yaml"), format: formatText})
+		require.Zero(t, rc, wErr.String())
+		assert.Contains(t, wOut.String(), "missing-foreign-key-index")
+	})
+}
+
 func TestLintFormatJson(t *testing.T) {
 	t.Parallel()
 
 	var wOut, wErr strings.Builder
-	rc := lint(&wOut, &wErr, []string{"testdata/patterns/*"}, nil, formatJson, false)
+	rc := runLint(&wOut, &wErr, []string{"testdata/patterns/*"}, options{format: formatJson})
 	require.Zero(t, rc, wErr.String())
 
 	out := wOut.String()
-	var report Report
+	var report lint.Report
 	err := json.NewDecoder(bytes.NewBuffer([]byte(out))).Decode(&report)
 	require.NoError(t, err)
 
 	assert.NotEmpty(t, report)
+	for _, v := range report {
+		assert.Positive(t, v.StartLine)
+		assert.Positive(t, v.StartColumn)
+		assert.GreaterOrEqual(t, v.EndLine, v.StartLine)
+		assert.NotEmpty(t, v.Message)
+	}
+}
+
+func TestLintFormatSarif(t *testing.T) {
+	t.Parallel()
+
+	var wOut, wErr strings.Builder
+	rc := runLint(&wOut, &wErr, []string{"testdata/breaking.sql"}, options{format: formatSarif})
+	require.Zero(t, rc, wErr.String())
+
+	var log sarifLog
+	require.NoError(t, json.Unmarshal([]byte(wOut.String()), &log))
+
+	assert.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+	assert.Equal(t, "pgvet", run.Tool.Driver.Name)
+	assert.Len(t, run.Tool.Driver.Rules, len(rules.AllRules()))
+	require.NotEmpty(t, run.Results)
+
+	for _, res := range run.Results {
+		assert.Equal(t, res.RuleID, run.Tool.Driver.Rules[res.RuleIndex].ID)
+		assert.Equal(t, "error", res.Level)
+		location := res.Locations[0].PhysicalLocation
+		assert.Equal(t, "testdata/breaking.sql", location.ArtifactLocation.URI)
+		assert.Positive(t, location.Region.StartLine)
+		assert.GreaterOrEqual(t, location.Region.EndLine, location.Region.StartLine)
+	}
+
+	first := run.Results[0].Locations[0].PhysicalLocation.Region
+	assert.Equal(t, "ALTER TABLE pgvet DROP COLUMN IF EXISTS value", first.Snippet.Text)
+	assert.Equal(t, first.StartLine, first.EndLine)
+}
+
+func TestSarifURI(t *testing.T) {
+	t.Parallel()
+
+	wd := filepath.FromSlash("/home/pgvet/repo")
+	cases := map[string]string{
+		"migrations/001.sql":                   "migrations/001.sql",
+		"./migrations/../migrations/001.sql":   "migrations/001.sql",
+		"migrations/001 init.sql":              "migrations/001%20init.sql",
+		"migrations/100%.sql":                  "migrations/100%25.sql",
+		"/home/pgvet/repo/migrations/001.sql":  "migrations/001.sql",
+		"/home/pgvet/other/migrations/001.sql": "file:///home/pgvet/other/migrations/001.sql",
+		"../other/001.sql":                     "file:///home/pgvet/other/001.sql",
+	}
+	for path, uri := range cases {
+		t.Run(path, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, uri, sarifURI(filepath.FromSlash(path), wd))
+		})
+	}
+}
+
+func TestLintFormatJunit(t *testing.T) {
+	t.Parallel()
+
+	var wOut, wErr strings.Builder
+	rc := runLint(&wOut, &wErr, []string{"testdata/breaking.sql", "testdata/noerrors.sql"}, options{format: formatJunit})
+	require.Zero(t, rc, wErr.String())
+
+	var suites junitTestSuites
+	require.NoError(t, xml.Unmarshal([]byte(wOut.String()), &suites))
+	require.Len(t, suites.Suites, 2)
+
+	numEnabled := len(lint.New(lint.DefaultConfig()).EnabledRules())
+
+	breaking := suites.Suites[0]
+	assert.Equal(t, "testdata/breaking.sql", breaking.Name)
+	assert.Equal(t, numEnabled, breaking.Tests)
+	assert.Len(t, breaking.TestCases, numEnabled)
+	assert.Positive(t, breaking.Failures)
+	for _, tc := range breaking.TestCases {
+		if tc.Name != "drop-column" {
+			continue
+		}
+		require.NotEmpty(t, tc.Failures)
+		assert.Contains(t, tc.Failures[0].Text, "testdata/breaking.sql:1")
+		assert.Contains(t, tc.Failures[0].Text, "ALTER TABLE pgvet DROP COLUMN IF EXISTS value")
+	}
+
+	// Files without violations still show up as passing suites
+	noerrors := suites.Suites[1]
+	assert.Equal(t, "testdata/noerrors.sql", noerrors.Name)
+	assert.Equal(t, numEnabled, noerrors.Tests)
+	assert.Zero(t, noerrors.Failures)
+
+	assert.Equal(t, breaking.Failures, suites.Failures)
+	assert.Equal(t, 2*numEnabled, suites.Tests)
+}
+
+func TestLintFormatGithub(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Annotations", func(t *testing.T) {
+		t.Parallel()
+
+		var wOut, wErr strings.Builder
+		rc := runLint(&wOut, &wErr, []string{"testdata/breaking.sql", "testdata/types.sql"}, options{format: formatGithub})
+		require.Zero(t, rc, wErr.String())
+
+		lines := strings.Split(strings.TrimSpace(wOut.String()), "\n")
+		assert.Equal(
+			t,
+			"::error file=testdata/breaking.sql,line=1,endLine=1,col=19,endColumn=46,title=drop-column::Dropping column public.pgvet.value. Update the application code to no longer use the column before applying the change",
+			lines[0],
+		)
+		assert.Contains(t, wOut.String(), "::notice file=testdata/types.sql,")
+	})
+
+	t.Run("Job summary", func(t *testing.T) {
+		t.Parallel()
+
+		summary := filepath.Join(t.TempDir(), "summary.md")
+		mustWriteFile(t, "# Previous step\n", summary)
+
+		var wOut, wErr strings.Builder
+		rc := runLint(&wOut, &wErr, []string{"testdata/breaking.sql", "testdata/noerrors.sql"}, options{format: formatGithub, githubStepSummary: summary})
+		require.Zero(t, rc, wErr.String())
+
+		content, err := os.ReadFile(summary)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(content), "# Previous step\n## pgvet\n"))
+		assert.Contains(t, string(content), "violation(s) found in 1 of 2 file(s)")
+		assert.Contains(t, string(content), "| error | [drop-column](https://github.com/ONordander/pgvet?tab=readme-ov-file#drop-column) | `testdata/breaking.sql:1` |")
+	})
+
+	t.Run("Escaping", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(t, "a%3Ab%2Cc%25d%0A", escapeGithubProperty("a:b,c%d\n"))
+		assert.Equal(t, "a:b,c%25d%0A", escapeGithubData("a:b,c%d\n"))
+	})
+}
+
+func TestLintFormatGitlab(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "migration.sql")
+	mustWriteFile(t, "DROP TABLE IF EXISTS pgvet;\n\nDROP TABLE IF EXISTS pgvet;\n", path)
+
+	var wOut, wErr strings.Builder
+	rc := runLint(&wOut, &wErr, []string{path}, options{format: formatGitlab})
+	require.Zero(t, rc, wErr.String())
+
+	var issues []gitlabIssue
+	require.NoError(t, json.Unmarshal([]byte(wOut.String()), &issues))
+	require.Len(t, issues, 2)
+
+	assert.Equal(t, "drop-table", issues[0].CheckName)
+	assert.Equal(t, "critical", issues[0].Severity)
+	assert.Equal(t, gitlabLines{Begin: 3, End: 3}, issues[1].Location.Lines)
+	// The same statement twice still gets unique fingerprints
+	assert.NotEqual(t, issues[0].Fingerprint, issues[1].Fingerprint)
+
+	// Nothing found is an empty array rather than null
+	wOut.Reset()
+	rc = runLint(&wOut, &wErr, []string{"testdata/noerrors.sql"}, options{format: formatGitlab})
+	require.Zero(t, rc, wErr.String())
+	assert.Equal(t, "[]\n", wOut.String())
+}
+
+func TestLintFormatCheckstyle(t *testing.T) {
+	t.Parallel()
+
+	var wOut, wErr strings.Builder
+	rc := runLint(&wOut, &wErr, []string{"testdata/breaking.sql", "testdata/noerrors.sql"}, options{format: formatCheckstyle})
+	require.Zero(t, rc, wErr.String())
+
+	var report checkstyleReport
+	require.NoError(t, xml.Unmarshal([]byte(wOut.String()), &report))
+	require.Len(t, report.Files, 2)
+
+	assert.Equal(t, "testdata/breaking.sql", report.Files[0].Name)
+	require.NotEmpty(t, report.Files[0].Errors)
+	assert.Equal(t, checkstyleError{
+		Line:     1,
+		Column:   19,
+		Severity: "error",
+		Message:  "Dropping column public.pgvet.value. Update the application code to no longer use the column before applying the change",
+		Source:   "pgvet.drop-column",
+	}, report.Files[0].Errors[0])
+
+	assert.Equal(t, "testdata/noerrors.sql", report.Files[1].Name)
+	assert.Empty(t, report.Files[1].Errors)
 }
 
 func TestLintError(t *testing.T) {
@@ -123,17 +422,20 @@ func TestLintError(t *testing.T) {
 	t.Run("Syntax error", func(t *testing.T) {
 		t.Parallel()
 		var wOut, wErr strings.Builder
-		rc := lint(&wOut, &wErr, []string{"testdata/error.sql"}, nil, formatText, false)
+		rc := runLint(&wOut, &wErr, []string{"testdata/error.sql", "testdata/breaking.sql"}, options{format: formatText})
 		require.NotZero(t, rc)
 
-		assert.Empty(t, wOut.String())
-		assert.Contains(t, wErr.String(), "Failed to parse SQL")
+		// The syntax error is reported along with the violations of the other files
+		out := wOut.String()
+		assert.Contains(t, out, "parse-error")
+		assert.Contains(t, out, "testdata/error.sql:1:8: Syntax error at or near \"ERROR\"")
+		assert.Contains(t, out, "testdata/breaking.sql")
 	})
 
 	t.Run("No files", func(t *testing.T) {
 		t.Parallel()
 		var wOut, wErr strings.Builder
-		rc := lint(&wOut, &wErr, []string{"testdata/missingfiles*.sql"}, nil, formatText, false)
+		rc := runLint(&wOut, &wErr, []string{"testdata/missingfiles*.sql"}, options{format: formatText})
 		require.NotZero(t, rc)
 
 		assert.Empty(t, wOut.String())
@@ -143,7 +445,7 @@ func TestLintError(t *testing.T) {
 	t.Run("Missing config", func(t *testing.T) {
 		t.Parallel()
 		var wOut, wErr strings.Builder
-		rc := lint(&wOut, &wErr, []string{"testdata/noerrors.sql"}, ptr("no-config.yaml"), formatText, false)
+		rc := runLint(&wOut, &wErr, []string{"testdata/noerrors.sql"}, options{configpath: ptr("no-config.yaml"), format: formatText})
 		require.NotZero(t, rc)
 
 		assert.Empty(t, wOut.String())
@@ -154,11 +456,73 @@ func TestLintError(t *testing.T) {
 func TestExitStatusOnViolations(t *testing.T) {
 	t.Parallel()
 	var wOut, wErr strings.Builder
-	rc := lint(&wOut, &wErr, []string{"testdata/breaking.sql"}, nil, formatText, true)
+	rc := runLint(&wOut, &wErr, []string{"testdata/breaking.sql"}, options{format: formatText, failOn: rules.SeverityInfo})
 	assert.NotZero(t, rc)
 	assert.NotEmpty(t, wOut.String())
 }
 
+func TestFailOn(t *testing.T) {
+	t.Parallel()
+
+	// types.sql only has violations of the info severity
+	cases := map[string]struct {
+		file     string
+		failOn   rules.Severity
+		expected int
+	}{
+		"error on error":   {"testdata/breaking.sql", rules.SeverityError, 1},
+		"info on error":    {"testdata/types.sql", rules.SeverityError, 0},
+		"info on warning":  {"testdata/types.sql", rules.SeverityWarning, 0},
+		"info on info":     {"testdata/types.sql", rules.SeverityInfo, 1},
+		"no severity":      {"testdata/breaking.sql", "", 0},
+		"unknown severity": {"testdata/breaking.sql", "critical", 1},
+		"no violations":    {"testdata/noerrors.sql", rules.SeverityInfo, 0},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var wOut, wErr strings.Builder
+			rc := runLint(&wOut, &wErr, []string{tc.file}, options{format: formatText, failOn: tc.failOn})
+			assert.Equal(t, tc.expected, rc, wErr.String())
+		})
+	}
+}
+
+func TestPostgresVersion(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		version    int
+		expected   []string
+		unexpected []string
+	}{
+		"PostgreSQL 10": {10, []string{"add-column-with-default", "unsupported-feature"}, nil},
+		"PostgreSQL 17": {17, nil, []string{"add-column-with-default", "unsupported-feature"}},
+		"default":       {0, nil, []string{"add-column-with-default", "unsupported-feature"}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var wOut, wErr strings.Builder
+			rc := runLint(&wOut, &wErr, []string{"testdata/version.sql"}, options{format: formatText, pgVersion: tc.version})
+			require.Zero(t, rc, wErr.String())
+			for _, code := range tc.expected {
+				assert.Contains(t, wOut.String(), code)
+			}
+			for _, code := range tc.unexpected {
+				assert.NotContains(t, wOut.String(), code)
+			}
+		})
+	}
+
+	t.Run("Unsupported version", func(t *testing.T) {
+		t.Parallel()
+		var wOut, wErr strings.Builder
+		rc := runLint(&wOut, &wErr, []string{"testdata/version.sql"}, options{format: formatText, pgVersion: 8})
+		assert.Equal(t, 1, rc)
+	})
+}
+
 func mustReadFile(t *testing.T, path string) string {
 	t.Helper()
 	content, err := os.ReadFile(path)
@@ -3,48 +3,59 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 
+	"github.com/onordander/pgvet/lint"
 	"github.com/onordander/pgvet/rules"
 )
 
 const (
-	violationFmt = `%s%s%s: %s:%d
+	violationFmt = `%s%s%s (%s): %s:%d:%d: %s
 
-%s
-  %sViolation%s: %s
+%s  %sViolation%s: %s
   %sSolution%s: %s
   %sExplanation%s: https://github.com/ONordander/pgvet?tab=readme-ov-file#%s
 %s
 `
 )
 
-type violation struct {
-	File          string     `json:"file"`
-	Code          rules.Code `json:"code"`
-	Statement     string     `json:"statement"`
-	StatementLine int        `json:"statementLine"`
-	Slug          string     `json:"slug"`
-	Help          string     `json:"help"`
+// lintRun is what the serializers get to work with: the violations as well as what was checked.
+type lintRun struct {
+	report lint.Report
+	// The linted files in the order they were linted.
+	files []string
+	// The enabled rules.
+	rules []rules.Rule
 }
 
-type Report []violation
-
-func (r Report) Serialize(format string) (string, error) {
-	if format == formatJson {
+func serialize(run lintRun, format string) (string, error) {
+	r := run.report
+	switch format {
+	case formatJson:
 		var b strings.Builder
 		if err := json.NewEncoder(&b).Encode(r); err != nil {
 			return "", err
 		}
 		return b.String(), nil
+	case formatSarif:
+		return serializeSarif(r)
+	case formatJunit:
+		return serializeJunit(run)
+	case formatGithub:
+		return serializeGithub(r), nil
+	case formatGitlab:
+		return serializeGitlab(r)
+	case formatCheckstyle:
+		return serializeCheckstyle(run)
 	}
 
 	var b strings.Builder
 	files := map[string]bool{}
-	for _, v := range r {
-		b.WriteString(formatViolation(v))
+	for _, group := range groupViolations(r) {
+		b.WriteString(formatViolations(group))
 		b.WriteString("\n")
-		files[v.File] = true
+		files[group[0].File] = true
 	}
 
 	summary := fmt.Sprintf("%s0 violations found %s\n", green, normal)
@@ -56,11 +67,36 @@ func (r Report) Serialize(format string) (string, error) {
 	return b.String(), nil
 }
 
-func formatViolation(v violation) string {
+// groupViolations groups the consecutive violations of the same rule in the same statement, e.g. for several
+// sub-commands of an ALTER TABLE statement.
+func groupViolations(r lint.Report) [][]lint.Violation {
+	var groups [][]lint.Violation
+	for i, v := range r {
+		if i > 0 {
+			prev := r[i-1]
+			if v.File == prev.File && v.Code == prev.Code && v.StatementLine == prev.StatementLine &&
+				v.StatementColumn == prev.StatementColumn {
+				groups[len(groups)-1] = append(groups[len(groups)-1], v)
+				continue
+			}
+		}
+		groups = append(groups, []lint.Violation{v})
+	}
+	return groups
+}
+
+// formatViolations formats the violations of a rule in a statement. The statement is shown once, with the message of
+// each violation next to the clause it is about if there are several.
+func formatViolations(group []lint.Violation) string {
+	v := group[0]
+	message := v.Message
+	if len(group) > 1 {
+		message = fmt.Sprintf("%d violations in the statement", len(group))
+	}
 	return fmt.Sprintf(
 		violationFmt,
-		red, v.Code, normal, v.File, v.StatementLine,
-		formatStatement(v.Statement, v.StatementLine),
+		severityColor(v.Severity), v.Code, normal, v.Severity, v.File, v.StartLine, v.StartColumn, message,
+		formatStatement(group),
 		bold, normal, v.Slug,
 		bold, normal, v.Help,
 		bold, normal, v.Code,
@@ -68,11 +104,69 @@ func formatViolation(v violation) string {
 	)
 }
 
-func formatStatement(stmt string, linestart int) string {
-	lines := strings.Split(strings.ReplaceAll(stmt, "\r\n", "\n"), "\n")
+// formatStatement returns the numbered lines of the statement with the parts the violations are about underlined,
+// followed by an empty line.
+func formatStatement(group []lint.Violation) string {
+	first := group[0]
+	// Violations about the file as a whole have no statement to show
+	if first.Statement == "" {
+		return ""
+	}
+	lines := strings.Split(strings.ReplaceAll(first.Statement, "\r\n", "\n"), "\n")
 	var msg strings.Builder
 	for i, line := range lines {
-		msg.WriteString(fmt.Sprintf("  %d | %s\n", linestart+i, line))
+		lineNum := first.StatementLine + i
+		msg.WriteString(fmt.Sprintf("  %d | %s\n", lineNum, line))
+
+		for _, v := range group {
+			if lineNum < v.StartLine || lineNum > v.EndLine {
+				continue
+			}
+			// The first line of the statement doesn't have to start at the beginning of the line in the file
+			offset := 1
+			if i == 0 {
+				offset = v.StatementColumn
+			}
+			runes := []rune(line)
+			start := len(runes) - len([]rune(strings.TrimLeft(line, " \t")))
+			if lineNum == v.StartLine {
+				start = max(v.StartColumn-offset, 0)
+			}
+			end := len(runes)
+			if lineNum == v.EndLine {
+				end = min(v.EndColumn-offset, len(runes))
+			}
+			if end <= start {
+				continue
+			}
+			var label string
+			if len(group) > 1 && lineNum == v.EndLine {
+				label = " " + v.Message
+			}
+			msg.WriteString(fmt.Sprintf(
+				"  %s | %s%s%s%s%s\n",
+				strings.Repeat(" ", len(strconv.Itoa(lineNum))),
+				strings.Repeat(" ", start),
+				severityColor(v.Severity), strings.Repeat("^", end-start), normal,
+				label,
+			))
+		}
 	}
+	msg.WriteString("\n")
 	return msg.String()
 }
+
+func explanationURL(code rules.Code) string {
+	return "https://github.com/ONordander/pgvet?tab=readme-ov-file#" + string(code)
+}
+
+func severityColor(severity rules.Severity) string {
+	switch severity {
+	case rules.SeverityWarning:
+		return yellow
+	case rules.SeverityInfo:
+		return cyan
+	default:
+		return red
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"github.com/onordander/pgvet/lint"
+)
+
+// The Checkstyle XML format, each linted file is listed with its violations.
+type (
+	checkstyleReport struct {
+		XMLName xml.Name         `xml:"checkstyle"`
+		Version string           `xml:"version,attr"`
+		Files   []checkstyleFile `xml:"file"`
+	}
+
+	checkstyleFile struct {
+		Name   string            `xml:"name,attr"`
+		Errors []checkstyleError `xml:"error"`
+	}
+
+	checkstyleError struct {
+		Line     int    `xml:"line,attr"`
+		Column   int    `xml:"column,attr"`
+		Severity string `xml:"severity,attr"`
+		Message  string `xml:"message,attr"`
+		Source   string `xml:"source,attr"`
+	}
+)
+
+func serializeCheckstyle(run lintRun) (string, error) {
+	violations := map[string][]lint.Violation{}
+	for _, v := range run.report {
+		violations[v.File] = append(violations[v.File], v)
+	}
+
+	report := checkstyleReport{Version: "4.3"}
+	for _, file := range run.files {
+		f := checkstyleFile{Name: file}
+		for _, v := range violations[file] {
+			f.Errors = append(f.Errors, checkstyleError{
+				Line:     v.StartLine,
+				Column:   v.StartColumn,
+				Severity: string(v.Severity),
+				Message:  v.Message + ". " + v.Help,
+				Source:   "pgvet." + string(v.Code),
+			})
+		}
+		report.Files = append(report.Files, f)
+	}
+
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&b)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return "", err
+	}
+	b.WriteString("\n")
+	return b.String(), nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/onordander/pgvet/lint"
+	"github.com/onordander/pgvet/rules"
+)
+
+// serializeGithub formats the violations as GitHub Actions workflow commands, which show up as annotations on the
+// pull request diff. See https://docs.github.com/en/actions/reference/workflow-commands-for-github-actions
+func serializeGithub(r lint.Report) string {
+	var b strings.Builder
+	for _, v := range r {
+		fmt.Fprintf(
+			&b,
+			"::%s file=%s,line=%d,endLine=%d,col=%d,endColumn=%d,title=%s::%s\n",
+			githubLevel(v.Severity),
+			escapeGithubProperty(v.File),
+			v.StartLine,
+			v.EndLine,
+			v.StartColumn,
+			v.EndColumn,
+			escapeGithubProperty(string(v.Code)),
+			escapeGithubData(v.Message+". "+v.Help),
+		)
+	}
+	return b.String()
+}
+
+func githubLevel(severity rules.Severity) string {
+	switch severity {
+	case rules.SeverityWarning:
+		return "warning"
+	case rules.SeverityInfo:
+		return "notice"
+	default:
+		return "error"
+	}
+}
+
+func escapeGithubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeGithubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// githubSummary returns a Markdown job summary of the run.
+func githubSummary(run lintRun) string {
+	var b strings.Builder
+	b.WriteString("## pgvet\n\n")
+
+	if len(run.report) == 0 {
+		fmt.Fprintf(&b, "No violations found in %d file(s).\n", len(run.files))
+		return b.String()
+	}
+
+	files := map[string]bool{}
+	for _, v := range run.report {
+		files[v.File] = true
+	}
+	fmt.Fprintf(&b, "%d violation(s) found in %d of %d file(s).\n\n", len(run.report), len(files), len(run.files))
+
+	b.WriteString("| Severity | Rule | Location | Violation |\n")
+	b.WriteString("| -------- | ---- | -------- | --------- |\n")
+	for _, v := range run.report {
+		fmt.Fprintf(
+			&b,
+			"| %s | [%s](%s) | `%s:%d` | %s |\n",
+			v.Severity,
+			v.Code,
+			explanationURL(v.Code),
+			escapeMarkdownCell(v.File),
+			v.StatementLine,
+			escapeMarkdownCell(v.Message),
+		)
+	}
+	return b.String()
+}
+
+func escapeMarkdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+// appendGithubSummary appends the job summary to the file GitHub Actions renders on the summary page of the run.
+func appendGithubSummary(path string, run lintRun) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(githubSummary(run)); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
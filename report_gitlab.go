@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/onordander/pgvet/lint"
+	"github.com/onordander/pgvet/rules"
+)
+
+// The GitLab Code Quality report, a subset of the Code Climate format.
+// See https://docs.gitlab.com/ci/testing/code_quality/#code-quality-report-format
+type (
+	gitlabIssue struct {
+		Description string         `json:"description"`
+		CheckName   string         `json:"check_name"`
+		Fingerprint string         `json:"fingerprint"`
+		Severity    string         `json:"severity"`
+		Location    gitlabLocation `json:"location"`
+	}
+
+	gitlabLocation struct {
+		Path  string      `json:"path"`
+		Lines gitlabLines `json:"lines"`
+	}
+
+	gitlabLines struct {
+		Begin int `json:"begin"`
+		End   int `json:"end"`
+	}
+)
+
+func serializeGitlab(r lint.Report) (string, error) {
+	issues := []gitlabIssue{}
+	seen := map[string]int{}
+	for _, v := range r {
+		fingerprint := v.Fingerprint()
+		// GitLab drops issues with the same fingerprint, e.g. the same statement twice in a file
+		if n := seen[fingerprint]; n > 0 {
+			seen[fingerprint]++
+			sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", fingerprint, n)))
+			fingerprint = hex.EncodeToString(sum[:])
+		} else {
+			seen[fingerprint] = 1
+		}
+
+		issues = append(issues, gitlabIssue{
+			Description: v.Message + ". " + v.Help,
+			CheckName:   string(v.Code),
+			Fingerprint: fingerprint,
+			Severity:    gitlabSeverity(v.Severity),
+			Location: gitlabLocation{
+				Path:  filepath.ToSlash(v.File),
+				Lines: gitlabLines{Begin: v.StartLine, End: v.EndLine},
+			},
+		})
+	}
+
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(issues); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func gitlabSeverity(severity rules.Severity) string {
+	switch severity {
+	case rules.SeverityWarning:
+		return "major"
+	case rules.SeverityInfo:
+		return "info"
+	default:
+		return "critical"
+	}
+}
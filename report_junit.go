@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/onordander/pgvet/lint"
+	"github.com/onordander/pgvet/rules"
+)
+
+// The JUnit XML format as understood by most CI systems, each linted file is a suite and each enabled rule a test case.
+type (
+	junitTestSuites struct {
+		XMLName  xml.Name         `xml:"testsuites"`
+		Name     string           `xml:"name,attr"`
+		Tests    int              `xml:"tests,attr"`
+		Failures int              `xml:"failures,attr"`
+		Suites   []junitTestSuite `xml:"testsuite"`
+	}
+
+	junitTestSuite struct {
+		Name      string          `xml:"name,attr"`
+		Tests     int             `xml:"tests,attr"`
+		Failures  int             `xml:"failures,attr"`
+		TestCases []junitTestCase `xml:"testcase"`
+	}
+
+	junitTestCase struct {
+		Name      string         `xml:"name,attr"`
+		ClassName string         `xml:"classname,attr"`
+		Failures  []junitFailure `xml:"failure"`
+	}
+
+	junitFailure struct {
+		Message string `xml:"message,attr"`
+		Type    string `xml:"type,attr"`
+		Text    string `xml:",cdata"`
+	}
+)
+
+func serializeJunit(run lintRun) (string, error) {
+	violations := map[string]map[rules.Code][]lint.Violation{}
+	for _, v := range run.report {
+		if violations[v.File] == nil {
+			violations[v.File] = map[rules.Code][]lint.Violation{}
+		}
+		violations[v.File][v.Code] = append(violations[v.File][v.Code], v)
+	}
+
+	suites := junitTestSuites{Name: "pgvet"}
+	for _, file := range run.files {
+		suite := junitTestSuite{Name: file, Tests: len(run.rules)}
+		for _, rule := range run.rules {
+			testCase := junitTestCase{Name: string(rule.Code), ClassName: file}
+			for _, v := range violations[file][rule.Code] {
+				testCase.Failures = append(testCase.Failures, junitFailure{
+					Message: v.Message,
+					Type:    string(v.Severity),
+					Text:    formatJunitFailure(v),
+				})
+			}
+			if len(testCase.Failures) > 0 {
+				suite.Failures++
+			}
+			suite.TestCases = append(suite.TestCases, testCase)
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&b)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return "", err
+	}
+	b.WriteString("\n")
+	return b.String(), nil
+}
+
+func formatJunitFailure(v lint.Violation) string {
+	return fmt.Sprintf(
+		"%s:%d\n\n%s\n\nViolation: %s\nSolution: %s\nExplanation: %s",
+		v.File, v.StatementLine, v.Statement, v.Slug, v.Help, explanationURL(v.Code),
+	)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/onordander/pgvet/lint"
+	"github.com/onordander/pgvet/rules"
+)
+
+const (
+	sarifSchema  = "https://docs.oasis-open.org/sarif/sarif/v2.1.0/errata01/os/schemas/sarif-schema-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+// The subset of SARIF 2.1.0 that pgvet produces, see https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+
+	sarifRun struct {
+		Tool    sarifTool     `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+
+	sarifDriver struct {
+		Name           string                     `json:"name"`
+		Version        string                     `json:"version"`
+		InformationURI string                     `json:"informationUri"`
+		Rules          []sarifReportingDescriptor `json:"rules"`
+	}
+
+	sarifReportingDescriptor struct {
+		ID                   string                  `json:"id"`
+		ShortDescription     sarifMessage            `json:"shortDescription"`
+		Help                 sarifMessage            `json:"help"`
+		HelpURI              string                  `json:"helpUri"`
+		DefaultConfiguration sarifRuleConfiguration  `json:"defaultConfiguration"`
+		Properties           sarifDescriptorProperty `json:"properties"`
+	}
+
+	sarifRuleConfiguration struct {
+		Enabled bool   `json:"enabled"`
+		Level   string `json:"level"`
+	}
+
+	sarifDescriptorProperty struct {
+		Tags []string `json:"tags"`
+	}
+
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+
+	sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		RuleIndex int             `json:"ruleIndex"`
+		Level     string          `json:"level"`
+		Message   sarifMessage    `json:"message"`
+		Locations []sarifLocation `json:"locations"`
+	}
+
+	sarifLocation struct {
+		PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	}
+
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+		Region           sarifRegion           `json:"region"`
+	}
+
+	sarifArtifactLocation struct {
+		URI string `json:"uri"`
+	}
+
+	sarifRegion struct {
+		StartLine   int          `json:"startLine"`
+		StartColumn int          `json:"startColumn"`
+		EndLine     int          `json:"endLine"`
+		EndColumn   int          `json:"endColumn"`
+		Snippet     sarifMessage `json:"snippet"`
+	}
+)
+
+func serializeSarif(r lint.Report) (string, error) {
+	driver := sarifDriver{
+		Name:           "pgvet",
+		Version:        strings.TrimSpace(version),
+		InformationURI: "https://github.com/ONordander/pgvet",
+	}
+	ruleIndex := map[rules.Code]int{}
+	for i, rule := range rules.AllRules() {
+		ruleIndex[rule.Code] = i
+		driver.Rules = append(driver.Rules, sarifReportingDescriptor{
+			ID:               string(rule.Code),
+			ShortDescription: sarifMessage{Text: rule.Slug},
+			Help:             sarifMessage{Text: rule.Help},
+			HelpURI:          explanationURL(rule.Code),
+			DefaultConfiguration: sarifRuleConfiguration{
+				Enabled: !rule.DisabledByDefault,
+				Level:   sarifLevel(rule.Severity),
+			},
+			Properties: sarifDescriptorProperty{Tags: []string{rule.Category}},
+		})
+	}
+
+	// The paths are made relative to the working directory, normally the root of the repository
+	wd, _ := os.Getwd()
+	results := []sarifResult{}
+	for _, v := range r {
+		results = append(results, sarifResult{
+			RuleID:    string(v.Code),
+			RuleIndex: ruleIndex[v.Code],
+			Level:     sarifLevel(v.Severity),
+			Message:   sarifMessage{Text: v.Message + ". " + v.Help},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: sarifURI(v.File, wd)},
+					Region: sarifRegion{
+						StartLine:   v.StartLine,
+						StartColumn: v.StartColumn,
+						EndLine:     v.EndLine,
+						EndColumn:   v.EndColumn,
+						Snippet:     sarifMessage{Text: v.Statement},
+					},
+				},
+			}},
+		})
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(log); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func sarifLevel(severity rules.Severity) string {
+	switch severity {
+	case rules.SeverityWarning:
+		return "warning"
+	case rules.SeverityInfo:
+		return "note"
+	default:
+		return "error"
+	}
+}
+
+// sarifURI returns the URI of the file at path, relative to wd when the file is in it. Files outside of wd get an
+// absolute file URI.
+func sarifURI(path, wd string) string {
+	if wd == "" {
+		return (&url.URL{Path: filepath.ToSlash(filepath.Clean(path))}).String()
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(wd, path)
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil && filepath.IsLocal(rel) {
+		return (&url.URL{Path: filepath.ToSlash(rel)}).String()
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+	if !strings.HasPrefix(path, "/") {
+		// A Windows path starts with the drive letter
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
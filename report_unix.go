@@ -6,7 +6,9 @@ package main
 const (
 	red     = "\033[1;31m"
 	green   = "\033[1;32m"
+	yellow  = "\033[1;33m"
 	magenta = "\033[1;35m"
+	cyan    = "\033[1;36m"
 	normal  = "\033[0m"
 	bold    = "\033[1m"
 )
@@ -7,7 +7,9 @@ package main
 const (
 	red     = ""
 	green   = ""
+	yellow  = ""
 	magenta = ""
+	cyan    = ""
 	normal  = ""
 	bold    = ""
 )
@@ -1,6 +1,9 @@
 package rules
 
 import (
+	"fmt"
+	"strings"
+
 	pgquery "github.com/pganalyze/pg_query_go/v6"
 )
 
@@ -11,6 +14,7 @@ var breakingRules = []Rule{
 		Help:     "Update the application code to no longer use the column before applying the change",
 		Fn:       dropColumn,
 		Category: breaking,
+		Severity: SeverityError,
 	},
 	{
 		Code:     "drop-table",
@@ -18,6 +22,7 @@ var breakingRules = []Rule{
 		Help:     "Update the application code to no longer use the table before applying the change",
 		Fn:       dropTable,
 		Category: breaking,
+		Severity: SeverityError,
 	},
 	{
 		Code:     "rename-column",
@@ -25,6 +30,7 @@ var breakingRules = []Rule{
 		Help:     "Add the new column as nullable and write to both from the application. Perform a backfill. Update application code to only use the new column. Delete the old column",
 		Fn:       renameColumn,
 		Category: breaking,
+		Severity: SeverityError,
 	},
 	{
 		Code:     "rename-table",
@@ -32,6 +38,7 @@ var breakingRules = []Rule{
 		Help:     "Add a new table and write to both from the application. Perform a backfill. Update application code to only use the new table. Delete the old table",
 		Fn:       renameTable,
 		Category: breaking,
+		Severity: SeverityError,
 	},
 	{
 		Code:     "change-column-type",
@@ -39,131 +46,62 @@ var breakingRules = []Rule{
 		Help:     "Add a new column with the new type and write to both from the application. Perform a backfill. Update application code to only use the new column. Delete the old column",
 		Fn:       changeColumnType,
 		Category: breaking,
+		Severity: SeverityError,
 	},
 }
 
-func dropColumn(
-	tree *pgquery.ParseResult,
-	code Code,
-	slug,
-	help string,
-	_ bool,
-) ([]Result, error) {
-	var results []Result
-	for _, decl := range FilterStatements[*pgquery.Node_AlterTableStmt](tree.Stmts) {
-		for _, cmd := range decl.Stmt.AlterTableStmt.GetCmds() {
-			subtype := cmd.GetAlterTableCmd().GetSubtype()
-			if subtype != pgquery.AlterTableType_AT_DropColumn {
-				continue
-			}
-			r := Result{
-				Slug:      slug,
-				Help:      help,
-				Code:      code,
-				StmtStart: decl.Start,
-				StmtEnd:   decl.End,
-			}
-			results = append(results, r)
+func dropColumn(ctx *RuleContext) error {
+	On(ctx, func(c *Cursor, cmd *pgquery.AlterTableCmd) {
+		if cmd.GetSubtype() == pgquery.AlterTableType_AT_DropColumn {
+			objects := alteredTable(c)
+			objects.Column = cmd.GetName()
+			ctx.Report(c, ctx.cmdSpan(c, cmd), objects, "Dropping column "+objects.qualifiedColumn())
 		}
-	}
-
-	return results, nil
+	})
+	return nil
 }
 
-func dropTable(
-	tree *pgquery.ParseResult,
-	code Code,
-	slug,
-	help string,
-	_ bool,
-) ([]Result, error) {
-	var results []Result
-	for _, decl := range FilterStatements[*pgquery.Node_DropStmt](tree.Stmts) {
-		if decl.Stmt.DropStmt.RemoveType == pgquery.ObjectType_OBJECT_TABLE {
-			r := Result{
-				Slug:      slug,
-				Help:      help,
-				Code:      code,
-				StmtStart: decl.Start,
-				StmtEnd:   decl.End,
-			}
-			results = append(results, r)
+func dropTable(ctx *RuleContext) error {
+	On(ctx, func(c *Cursor, stmt *pgquery.DropStmt) {
+		if stmt.GetRemoveType() == pgquery.ObjectType_OBJECT_TABLE {
+			objects, names := droppedObjects(stmt)
+			ctx.Report(c, ctx.stmtSpan(c), objects, "Dropping table "+strings.Join(names, ", "))
 		}
-	}
-
-	return results, nil
+	})
+	return nil
 }
 
-func renameColumn(
-	tree *pgquery.ParseResult,
-	code Code,
-	slug,
-	help string,
-	_ bool,
-) ([]Result, error) {
-	var results []Result
-	for _, decl := range FilterStatements[*pgquery.Node_RenameStmt](tree.Stmts) {
-		if decl.Stmt.RenameStmt.RenameType != pgquery.ObjectType_OBJECT_COLUMN {
-			continue
+func renameColumn(ctx *RuleContext) error {
+	On(ctx, func(c *Cursor, stmt *pgquery.RenameStmt) {
+		if stmt.GetRenameType() == pgquery.ObjectType_OBJECT_COLUMN {
+			objects := tableObjects(stmt.GetRelation())
+			objects.Column = stmt.GetSubname()
+			message := fmt.Sprintf("Renaming column %s to %s", objects.qualifiedColumn(), stmt.GetNewname())
+			ctx.Report(c, ctx.stmtSpan(c), objects, message)
 		}
-		r := Result{
-			Slug:      slug,
-			Help:      help,
-			Code:      code,
-			StmtStart: decl.Start,
-			StmtEnd:   decl.End,
-		}
-		results = append(results, r)
-	}
-	return results, nil
+	})
+	return nil
 }
 
-func renameTable(
-	tree *pgquery.ParseResult,
-	code Code,
-	slug,
-	help string,
-	_ bool,
-) ([]Result, error) {
-	var results []Result
-	for _, decl := range FilterStatements[*pgquery.Node_RenameStmt](tree.Stmts) {
-		if decl.Stmt.RenameStmt.RenameType != pgquery.ObjectType_OBJECT_TABLE {
-			continue
-		}
-		r := Result{
-			Slug:      slug,
-			Help:      help,
-			Code:      code,
-			StmtStart: decl.Start,
-			StmtEnd:   decl.End,
+func renameTable(ctx *RuleContext) error {
+	On(ctx, func(c *Cursor, stmt *pgquery.RenameStmt) {
+		if stmt.GetRenameType() == pgquery.ObjectType_OBJECT_TABLE {
+			objects := tableObjects(stmt.GetRelation())
+			message := fmt.Sprintf("Renaming table %s to %s", objects.qualifiedTable(), stmt.GetNewname())
+			ctx.Report(c, ctx.stmtSpan(c), objects, message)
 		}
-		results = append(results, r)
-	}
-	return results, nil
+	})
+	return nil
 }
 
-func changeColumnType(
-	tree *pgquery.ParseResult,
-	code Code,
-	slug,
-	help string,
-	_ bool,
-) ([]Result, error) {
-	var results []Result
-	for _, decl := range FilterStatements[*pgquery.Node_AlterTableStmt](tree.Stmts) {
-		for _, cmd := range decl.Stmt.AlterTableStmt.GetCmds() {
-			alterTableCmd := cmd.GetAlterTableCmd()
-			if alterTableCmd.GetSubtype() == pgquery.AlterTableType_AT_AlterColumnType {
-				r := Result{
-					Slug:      slug,
-					Help:      help,
-					Code:      code,
-					StmtStart: decl.Start,
-					StmtEnd:   decl.End,
-				}
-				results = append(results, r)
-			}
+func changeColumnType(ctx *RuleContext) error {
+	On(ctx, func(c *Cursor, cmd *pgquery.AlterTableCmd) {
+		if cmd.GetSubtype() == pgquery.AlterTableType_AT_AlterColumnType && !isAlteringNewTable(ctx, c) {
+			objects := alteredTable(c)
+			objects.Column = cmd.GetName()
+			message := fmt.Sprintf("Changing the type of column %s to %s", objects.qualifiedColumn(), typeName(cmd.GetDef().GetColumnDef().GetTypeName()))
+			ctx.Report(c, ctx.cmdSpan(c, cmd), objects, message)
 		}
-	}
-	return results, nil
+	})
+	return nil
 }
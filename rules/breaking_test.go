@@ -23,7 +23,7 @@ func TestDropColumn(t *testing.T) {
 		tree := mustParse(t, "ALTER TABLE pgvet DROP COLUMN value;")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := dropColumn(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, dropColumn, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 1)
 
@@ -44,7 +44,7 @@ func TestDropColumn(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 3)
 
-		res, err := dropColumn(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, dropColumn, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 2)
 
@@ -62,7 +62,7 @@ func TestDropColumn(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 3)
 
-		res, err := dropColumn(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, dropColumn, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		assert.Empty(t, res)
 	})
@@ -77,7 +77,7 @@ func TestDropTable(t *testing.T) {
 		tree := mustParse(t, "DROP TABLE pgvet;")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := dropTable(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, dropTable, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 1)
 
@@ -98,7 +98,7 @@ func TestDropTable(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 3)
 
-		res, err := dropTable(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, dropTable, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 2)
 
@@ -116,7 +116,7 @@ func TestRenameColumn(t *testing.T) {
 		tree := mustParse(t, "ALTER TABLE pgvet RENAME COLUMN value TO value2;")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := renameColumn(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, renameColumn, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 1)
 
@@ -137,7 +137,7 @@ func TestRenameColumn(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 3)
 
-		res, err := renameColumn(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, renameColumn, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 2)
 
@@ -155,7 +155,7 @@ func TestRenameTable(t *testing.T) {
 		tree := mustParse(t, "ALTER TABLE pgvet RENAME TO pgvet_new;")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := renameTable(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, renameTable, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 1)
 
@@ -176,7 +176,7 @@ func TestRenameTable(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 3)
 
-		res, err := renameTable(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, renameTable, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 2)
 
@@ -194,7 +194,7 @@ func TestChangeColumnType(t *testing.T) {
 		tree := mustParse(t, "ALTER TABLE pgvet ALTER COLUMN value TYPE text;")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := changeColumnType(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, changeColumnType, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 1)
 
@@ -215,11 +215,26 @@ func TestChangeColumnType(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 3)
 
-		res, err := changeColumnType(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, changeColumnType, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 2)
 
 		assert.EqualValues(t, 0, res[0].StmtStart)
 		assert.EqualValues(t, 96, res[1].StmtStart)
 	})
+
+	t.Run("Should not flag tables created in the same file", func(t *testing.T) {
+		t.Parallel()
+
+		var b strings.Builder
+		b.WriteString("CREATE TABLE app.pgvet (id text PRIMARY KEY, value text);\n")
+		b.WriteString("ALTER TABLE app.pgvet ALTER COLUMN value TYPE varchar(36);\n")
+		b.WriteString("ALTER TABLE pgvet ALTER COLUMN value TYPE varchar(36);\n")
+		tree := mustParse(t, b.String())
+		require.Len(t, tree.Stmts, 3)
+
+		res, err := check(t, changeColumnType, tree, checkOptions{ImplicitTransaction: true})
+		require.NoError(t, err)
+		assert.Len(t, res, 1)
+	})
 }
@@ -0,0 +1,655 @@
+package rules
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	pgquery "github.com/pganalyze/pg_query_go/v6"
+)
+
+const defaultSchema = "public"
+
+// Catalog is an in-memory model of the schema built by replaying the migrations in order.
+// Objects are keyed by their schema qualified name, e.g. public.pgvet.
+type Catalog struct {
+	Schemas   map[string]bool
+	Tables    map[string]*Table
+	Indexes   map[string]*Index
+	Sequences map[string]*Sequence
+	Enums     map[string]*Enum
+
+	// The tables created in the file being replayed.
+	newTables []*Table
+	// Records how to undo the changes since Savepoint, nil if they aren't recorded.
+	savepoint *savepoint
+}
+
+// savepoint records how to undo the changes made to the catalog since it was taken.
+type savepoint struct {
+	undo []func()
+	// The objects whose state at the savepoint has been recorded.
+	saved map[any]bool
+}
+
+type Table struct {
+	Schema      string
+	Name        string
+	Columns     []*Column
+	Constraints []*Constraint
+	// The file the table was created in.
+	File string
+	// Set while the table is empty and the file that created it hasn't been deployed, until rows are added to it or
+	// the file ends.
+	New bool
+}
+
+type Column struct {
+	Name       string
+	Type       string
+	NotNull    bool
+	HasDefault bool
+}
+
+type Constraint struct {
+	// Empty if the constraint was added without a name.
+	Name    string
+	Type    pgquery.ConstrType
+	Columns []string
+	// False if the constraint was added as NOT VALID and hasn't been validated since.
+	Validated bool
+}
+
+type Index struct {
+	Schema  string
+	Name    string
+	Table   string
+	Columns []string
+	Unique  bool
+}
+
+type Sequence struct {
+	Schema string
+	Name   string
+}
+
+type Enum struct {
+	Schema string
+	Name   string
+	Values []string
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{
+		Schemas:   map[string]bool{defaultSchema: true},
+		Tables:    map[string]*Table{},
+		Indexes:   map[string]*Index{},
+		Sequences: map[string]*Sequence{},
+		Enums:     map[string]*Enum{},
+	}
+}
+
+// Table returns the table referenced by the relation, or nil if it isn't known.
+func (c *Catalog) Table(relation *pgquery.RangeVar) *Table {
+	return c.Tables[qualify(relation.GetSchemaname(), relation.GetRelname())]
+}
+
+// Column returns the column with the given name, or nil if it doesn't exist.
+func (t *Table) Column(name string) *Column {
+	for _, col := range t.Columns {
+		if col.Name == name {
+			return col
+		}
+	}
+	return nil
+}
+
+// IsIndexed reports whether the column is the leading column of an index on the table.
+func (c *Catalog) IsIndexed(relation *pgquery.RangeVar, column string) bool {
+	table := qualify(relation.GetSchemaname(), relation.GetRelname())
+	for _, index := range c.Indexes {
+		if index.Table == table && len(index.Columns) > 0 && index.Columns[0] == column {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyTree replays every statement in the tree, the file ends after the last one.
+func (c *Catalog) ApplyTree(tree *pgquery.ParseResult, file string) {
+	for _, stmt := range tree.GetStmts() {
+		c.Apply(stmt.GetStmt(), file)
+	}
+	c.endFile()
+}
+
+// endFile records that the file being replayed has been deployed, so the tables it created are no longer new.
+func (c *Catalog) endFile() {
+	for _, table := range c.newTables {
+		saveObject(c, table, cloneTable)
+		table.New = false
+	}
+	c.newTables = nil
+}
+
+// populate records that rows are added to the table, so it's no longer new.
+func (c *Catalog) populate(relation *pgquery.RangeVar) {
+	if table := c.Table(relation); table != nil && table.New {
+		saveObject(c, table, cloneTable)
+		table.New = false
+	}
+}
+
+// Apply updates the catalog with the changes made by the statement. Statements that don't change the schema
+// or refer to unknown objects are ignored.
+func (c *Catalog) Apply(stmt *pgquery.Node, file string) {
+	c.apply(stmt, "", file)
+}
+
+// apply updates the catalog with the statement, unqualified objects are created in the given schema.
+func (c *Catalog) apply(stmt *pgquery.Node, schema, file string) {
+	switch {
+	case stmt.GetCreateSchemaStmt() != nil:
+		schemaStmt := stmt.GetCreateSchemaStmt()
+		saveEntry(c, c.Schemas, schemaStmt.GetSchemaname())
+		c.Schemas[schemaStmt.GetSchemaname()] = true
+		for _, elt := range schemaStmt.GetSchemaElts() {
+			c.apply(elt, schemaStmt.GetSchemaname(), file)
+		}
+	case stmt.GetCreateStmt() != nil:
+		c.createTable(stmt.GetCreateStmt(), schema, file)
+	case stmt.GetInsertStmt() != nil:
+		c.populate(stmt.GetInsertStmt().GetRelation())
+	case stmt.GetCopyStmt() != nil:
+		if stmt.GetCopyStmt().GetIsFrom() {
+			c.populate(stmt.GetCopyStmt().GetRelation())
+		}
+	case stmt.GetMergeStmt() != nil:
+		c.populate(stmt.GetMergeStmt().GetRelation())
+	case stmt.GetAlterTableStmt() != nil:
+		c.alterTable(stmt.GetAlterTableStmt())
+	case stmt.GetIndexStmt() != nil:
+		c.createIndex(stmt.GetIndexStmt(), schema)
+	case stmt.GetRenameStmt() != nil:
+		c.rename(stmt.GetRenameStmt())
+	case stmt.GetDropStmt() != nil:
+		c.drop(stmt.GetDropStmt())
+	case stmt.GetCreateSeqStmt() != nil:
+		seq := stmt.GetCreateSeqStmt().GetSequence()
+		seqSchema := schemaOr(seq.GetSchemaname(), schema)
+		key := qualify(seqSchema, seq.GetRelname())
+		saveEntry(c, c.Sequences, key)
+		c.Sequences[key] = &Sequence{Schema: seqSchema, Name: seq.GetRelname()}
+	case stmt.GetCreateEnumStmt() != nil:
+		enumStmt := stmt.GetCreateEnumStmt()
+		enumSchema, name := splitName(enumStmt.GetTypeName())
+		enum := &Enum{Schema: enumSchema, Name: name}
+		for _, val := range enumStmt.GetVals() {
+			enum.Values = append(enum.Values, val.GetString_().GetSval())
+		}
+		saveEntry(c, c.Enums, qualify(enumSchema, name))
+		c.Enums[qualify(enumSchema, name)] = enum
+	case stmt.GetAlterEnumStmt() != nil:
+		enumStmt := stmt.GetAlterEnumStmt()
+		enumSchema, name := splitName(enumStmt.GetTypeName())
+		if enum, ok := c.Enums[qualify(enumSchema, name)]; ok && enumStmt.GetNewVal() != "" {
+			saveObject(c, enum, cloneEnum)
+			if enumStmt.GetOldVal() != "" {
+				// RENAME VALUE
+				if i := slices.Index(enum.Values, enumStmt.GetOldVal()); i >= 0 {
+					enum.Values[i] = enumStmt.GetNewVal()
+				}
+			} else if !slices.Contains(enum.Values, enumStmt.GetNewVal()) {
+				enum.Values = append(enum.Values, enumStmt.GetNewVal())
+			}
+		}
+	}
+}
+
+func (c *Catalog) createTable(stmt *pgquery.CreateStmt, schema, file string) {
+	relation := stmt.GetRelation()
+	table := &Table{
+		Schema: schemaOr(relation.GetSchemaname(), schema),
+		Name:   relation.GetRelname(),
+		File:   file,
+		New:    true,
+	}
+	key := qualify(table.Schema, table.Name)
+	if _, exists := c.Tables[key]; exists && stmt.GetIfNotExists() {
+		return
+	}
+	saveEntry(c, c.Tables, key)
+	c.Tables[key] = table
+	c.newTables = append(c.newTables, table)
+
+	for _, elt := range stmt.GetTableElts() {
+		if col := elt.GetColumnDef(); col != nil {
+			c.addColumn(table, col)
+		}
+		if constraint := elt.GetConstraint(); constraint != nil {
+			c.addConstraint(table, constraint, nil)
+		}
+	}
+}
+
+func (c *Catalog) alterTable(stmt *pgquery.AlterTableStmt) {
+	table := c.Table(stmt.GetRelation())
+	if table == nil {
+		return
+	}
+	saveObject(c, table, cloneTable)
+
+	for _, node := range stmt.GetCmds() {
+		cmd := node.GetAlterTableCmd()
+		switch cmd.GetSubtype() {
+		case pgquery.AlterTableType_AT_AddColumn:
+			if col := cmd.GetDef().GetColumnDef(); col != nil && table.Column(col.GetColname()) == nil {
+				c.addColumn(table, col)
+			}
+		case pgquery.AlterTableType_AT_DropColumn:
+			table.Columns = slices.DeleteFunc(table.Columns, func(col *Column) bool {
+				return col.Name == cmd.GetName()
+			})
+			table.Constraints = slices.DeleteFunc(table.Constraints, func(constraint *Constraint) bool {
+				return slices.Contains(constraint.Columns, cmd.GetName())
+			})
+			for key, index := range c.Indexes {
+				if index.Table == qualify(table.Schema, table.Name) && slices.Contains(index.Columns, cmd.GetName()) {
+					saveEntry(c, c.Indexes, key)
+					delete(c.Indexes, key)
+				}
+			}
+		case pgquery.AlterTableType_AT_AlterColumnType:
+			if col := table.Column(cmd.GetName()); col != nil {
+				col.Type = typeName(cmd.GetDef().GetColumnDef().GetTypeName())
+			}
+		case pgquery.AlterTableType_AT_SetNotNull, pgquery.AlterTableType_AT_DropNotNull:
+			if col := table.Column(cmd.GetName()); col != nil {
+				col.NotNull = cmd.GetSubtype() == pgquery.AlterTableType_AT_SetNotNull
+			}
+		case pgquery.AlterTableType_AT_ColumnDefault:
+			if col := table.Column(cmd.GetName()); col != nil {
+				col.HasDefault = cmd.GetDef() != nil
+			}
+		case pgquery.AlterTableType_AT_AddConstraint:
+			c.addConstraint(table, cmd.GetDef().GetConstraint(), nil)
+		case pgquery.AlterTableType_AT_DropConstraint:
+			table.Constraints = slices.DeleteFunc(table.Constraints, func(constraint *Constraint) bool {
+				return constraint.Name == cmd.GetName()
+			})
+			saveEntry(c, c.Indexes, qualify(table.Schema, cmd.GetName()))
+			delete(c.Indexes, qualify(table.Schema, cmd.GetName()))
+		case pgquery.AlterTableType_AT_ValidateConstraint:
+			for _, constraint := range table.Constraints {
+				if constraint.Name == cmd.GetName() {
+					constraint.Validated = true
+				}
+			}
+		}
+	}
+}
+
+func (c *Catalog) addColumn(table *Table, def *pgquery.ColumnDef) {
+	col := &Column{
+		Name: def.GetColname(),
+		Type: typeName(def.GetTypeName()),
+	}
+	table.Columns = append(table.Columns, col)
+
+	for _, node := range def.GetConstraints() {
+		constraint := node.GetConstraint()
+		switch constraint.GetContype() {
+		case pgquery.ConstrType_CONSTR_NOTNULL, pgquery.ConstrType_CONSTR_PRIMARY:
+			col.NotNull = true
+		case pgquery.ConstrType_CONSTR_DEFAULT, pgquery.ConstrType_CONSTR_IDENTITY, pgquery.ConstrType_CONSTR_GENERATED:
+			col.HasDefault = true
+		}
+		c.addConstraint(table, constraint, col)
+	}
+
+	// serial types are backed by a sequence and a default
+	if strings.HasSuffix(col.Type, "serial") {
+		col.HasDefault = true
+		col.NotNull = true
+		name := fmt.Sprintf("%s_%s_seq", table.Name, col.Name)
+		saveEntry(c, c.Sequences, qualify(table.Schema, name))
+		c.Sequences[qualify(table.Schema, name)] = &Sequence{Schema: table.Schema, Name: name}
+	}
+}
+
+// addConstraint adds a table constraint. For column constraints col is the column it's declared on.
+func (c *Catalog) addConstraint(table *Table, def *pgquery.Constraint, col *Column) {
+	var columns []string
+	switch def.GetContype() {
+	case pgquery.ConstrType_CONSTR_PRIMARY, pgquery.ConstrType_CONSTR_UNIQUE,
+		pgquery.ConstrType_CONSTR_CHECK, pgquery.ConstrType_CONSTR_EXCLUSION:
+		columns = stringValues(def.GetKeys())
+	case pgquery.ConstrType_CONSTR_FOREIGN:
+		columns = stringValues(def.GetFkAttrs())
+	default:
+		// NOT NULL, DEFAULT etc. are tracked on the column
+		return
+	}
+	if col != nil && len(columns) == 0 {
+		columns = []string{col.Name}
+	}
+
+	table.Constraints = append(table.Constraints, &Constraint{
+		Name:      def.GetConname(),
+		Type:      def.GetContype(),
+		Columns:   columns,
+		Validated: !def.GetSkipValidation(),
+	})
+
+	// Primary keys and unique constraints are backed by an index
+	isUnique := def.GetContype() == pgquery.ConstrType_CONSTR_UNIQUE
+	if def.GetContype() == pgquery.ConstrType_CONSTR_PRIMARY || isUnique {
+		for _, name := range columns {
+			if existing := table.Column(name); existing != nil && !isUnique {
+				existing.NotNull = true
+			}
+		}
+
+		name := def.GetConname()
+		if name == "" && def.GetIndexname() != "" {
+			name = def.GetIndexname()
+		} else if name == "" && isUnique {
+			name = fmt.Sprintf("%s_%s_key", table.Name, strings.Join(columns, "_"))
+		} else if name == "" {
+			name = table.Name + "_pkey"
+		}
+		saveEntry(c, c.Indexes, qualify(table.Schema, name))
+		c.Indexes[qualify(table.Schema, name)] = &Index{
+			Schema:  table.Schema,
+			Name:    name,
+			Table:   qualify(table.Schema, table.Name),
+			Columns: columns,
+			Unique:  true,
+		}
+	}
+}
+
+func (c *Catalog) createIndex(stmt *pgquery.IndexStmt, schema string) {
+	relation := stmt.GetRelation()
+	schema = schemaOr(relation.GetSchemaname(), schema)
+
+	var columns []string
+	for _, param := range stmt.GetIndexParams() {
+		// Expression indexes have no column name
+		columns = append(columns, param.GetIndexElem().GetName())
+	}
+
+	name := stmt.GetIdxname()
+	if name == "" {
+		name = fmt.Sprintf("%s_%s_idx", relation.GetRelname(), strings.Join(columns, "_"))
+	}
+	key := qualify(schema, name)
+	if _, exists := c.Indexes[key]; exists && stmt.GetIfNotExists() {
+		return
+	}
+
+	saveEntry(c, c.Indexes, key)
+	c.Indexes[key] = &Index{
+		Schema:  schema,
+		Name:    name,
+		Table:   qualify(schema, relation.GetRelname()),
+		Columns: columns,
+		Unique:  stmt.GetUnique(),
+	}
+}
+
+func (c *Catalog) rename(stmt *pgquery.RenameStmt) {
+	relation := stmt.GetRelation()
+	key := qualify(relation.GetSchemaname(), relation.GetRelname())
+
+	switch stmt.GetRenameType() {
+	case pgquery.ObjectType_OBJECT_TABLE:
+		table, ok := c.Tables[key]
+		if !ok {
+			return
+		}
+		saveObject(c, table, cloneTable)
+		saveEntry(c, c.Tables, key)
+		delete(c.Tables, key)
+		table.Name = stmt.GetNewname()
+		newKey := qualify(table.Schema, table.Name)
+		saveEntry(c, c.Tables, newKey)
+		c.Tables[newKey] = table
+		for _, index := range c.Indexes {
+			if index.Table == key {
+				saveObject(c, index, cloneIndex)
+				index.Table = newKey
+			}
+		}
+	case pgquery.ObjectType_OBJECT_COLUMN:
+		table, ok := c.Tables[key]
+		if !ok {
+			return
+		}
+		saveObject(c, table, cloneTable)
+		if col := table.Column(stmt.GetSubname()); col != nil {
+			col.Name = stmt.GetNewname()
+		}
+		for _, constraint := range table.Constraints {
+			if i := slices.Index(constraint.Columns, stmt.GetSubname()); i >= 0 {
+				constraint.Columns[i] = stmt.GetNewname()
+			}
+		}
+		for _, index := range c.Indexes {
+			if i := slices.Index(index.Columns, stmt.GetSubname()); index.Table == key && i >= 0 {
+				saveObject(c, index, cloneIndex)
+				index.Columns[i] = stmt.GetNewname()
+			}
+		}
+	case pgquery.ObjectType_OBJECT_TABCONSTRAINT:
+		if table, ok := c.Tables[key]; ok {
+			saveObject(c, table, cloneTable)
+			for _, constraint := range table.Constraints {
+				if constraint.Name == stmt.GetSubname() {
+					constraint.Name = stmt.GetNewname()
+				}
+			}
+		}
+	case pgquery.ObjectType_OBJECT_INDEX:
+		if index, ok := c.Indexes[key]; ok {
+			saveObject(c, index, cloneIndex)
+			saveEntry(c, c.Indexes, key)
+			delete(c.Indexes, key)
+			index.Name = stmt.GetNewname()
+			saveEntry(c, c.Indexes, qualify(index.Schema, index.Name))
+			c.Indexes[qualify(index.Schema, index.Name)] = index
+		}
+	}
+}
+
+func (c *Catalog) drop(stmt *pgquery.DropStmt) {
+	for _, object := range stmt.GetObjects() {
+		var schema, name string
+		if typ := object.GetTypeName(); typ != nil {
+			schema, name = splitName(typ.GetNames())
+		} else if list := object.GetList(); list != nil {
+			schema, name = splitName(list.GetItems())
+		} else {
+			name = object.GetString_().GetSval()
+		}
+		key := qualify(schema, name)
+
+		switch stmt.GetRemoveType() {
+		case pgquery.ObjectType_OBJECT_TABLE:
+			saveEntry(c, c.Tables, key)
+			delete(c.Tables, key)
+			for indexKey, index := range c.Indexes {
+				if index.Table == key {
+					saveEntry(c, c.Indexes, indexKey)
+					delete(c.Indexes, indexKey)
+				}
+			}
+		case pgquery.ObjectType_OBJECT_INDEX:
+			saveEntry(c, c.Indexes, key)
+			delete(c.Indexes, key)
+		case pgquery.ObjectType_OBJECT_SEQUENCE:
+			saveEntry(c, c.Sequences, key)
+			delete(c.Sequences, key)
+		case pgquery.ObjectType_OBJECT_TYPE:
+			saveEntry(c, c.Enums, key)
+			delete(c.Enums, key)
+		case pgquery.ObjectType_OBJECT_SCHEMA:
+			saveEntry(c, c.Schemas, name)
+			delete(c.Schemas, name)
+			for tableKey, table := range c.Tables {
+				if table.Schema == name {
+					saveEntry(c, c.Tables, tableKey)
+					delete(c.Tables, tableKey)
+				}
+			}
+			for indexKey, index := range c.Indexes {
+				if index.Schema == name {
+					saveEntry(c, c.Indexes, indexKey)
+					delete(c.Indexes, indexKey)
+				}
+			}
+		}
+	}
+}
+
+// Clone returns a deep copy of the catalog.
+func (c *Catalog) Clone() *Catalog {
+	clone := NewCatalog()
+	for schema := range c.Schemas {
+		clone.Schemas[schema] = true
+	}
+	for key, table := range c.Tables {
+		clone.Tables[key] = cloneTable(table)
+	}
+	for key, index := range c.Indexes {
+		clone.Indexes[key] = cloneIndex(index)
+	}
+	for key, seq := range c.Sequences {
+		s := *seq
+		clone.Sequences[key] = &s
+	}
+	for key, enum := range c.Enums {
+		clone.Enums[key] = cloneEnum(enum)
+	}
+	return clone
+}
+
+// Savepoint starts recording the changes to the catalog so Rollback can undo them, e.g. to check a down migration
+// without changing the schema the later migrations are checked against. Only the objects that change are copied.
+func (c *Catalog) Savepoint() {
+	c.savepoint = &savepoint{saved: map[any]bool{}}
+}
+
+// Rollback undoes the changes made since Savepoint and stops recording them.
+func (c *Catalog) Rollback() {
+	if c.savepoint == nil {
+		return
+	}
+	for i := len(c.savepoint.undo) - 1; i >= 0; i-- {
+		c.savepoint.undo[i]()
+	}
+	c.savepoint = nil
+}
+
+// saveEntry records the entry of the map before it's set or deleted.
+func saveEntry[V any](c *Catalog, objects map[string]V, key string) {
+	if c.savepoint == nil {
+		return
+	}
+	old, ok := objects[key]
+	c.savepoint.undo = append(c.savepoint.undo, func() {
+		if ok {
+			objects[key] = old
+		} else {
+			delete(objects, key)
+		}
+	})
+}
+
+// saveObject records the state of the object before it's changed in place, the first time it changes.
+func saveObject[T any](c *Catalog, object *T, clone func(*T) *T) {
+	if c.savepoint == nil || c.savepoint.saved[object] {
+		return
+	}
+	c.savepoint.saved[object] = true
+	saved := clone(object)
+	c.savepoint.undo = append(c.savepoint.undo, func() { *object = *saved })
+}
+
+func cloneTable(table *Table) *Table {
+	t := *table
+	t.Columns = make([]*Column, len(table.Columns))
+	for i, col := range table.Columns {
+		copied := *col
+		t.Columns[i] = &copied
+	}
+	t.Constraints = make([]*Constraint, len(table.Constraints))
+	for i, constraint := range table.Constraints {
+		copied := *constraint
+		copied.Columns = slices.Clone(constraint.Columns)
+		t.Constraints[i] = &copied
+	}
+	return &t
+}
+
+func cloneIndex(index *Index) *Index {
+	i := *index
+	i.Columns = slices.Clone(index.Columns)
+	return &i
+}
+
+func cloneEnum(enum *Enum) *Enum {
+	e := *enum
+	e.Values = slices.Clone(enum.Values)
+	return &e
+}
+
+func qualify(schema, name string) string {
+	return schemaOr(schema, defaultSchema) + "." + name
+}
+
+// schemaOr returns the schema, or the fallback if the name isn't qualified.
+func schemaOr(schema, fallback string) string {
+	if schema != "" {
+		return schema
+	}
+	if fallback != "" {
+		return fallback
+	}
+	return defaultSchema
+}
+
+// splitName splits a possibly qualified name, e.g. public.pgvet, into its schema and name.
+func splitName(nodes []*pgquery.Node) (string, string) {
+	names := stringValues(nodes)
+	switch len(names) {
+	case 0:
+		return defaultSchema, ""
+	case 1:
+		return defaultSchema, names[0]
+	default:
+		return names[len(names)-2], names[len(names)-1]
+	}
+}
+
+// typeName formats the type, leaving out the implicit pg_catalog schema.
+func typeName(typ *pgquery.TypeName) string {
+	names := slices.DeleteFunc(stringValues(typ.GetNames()), func(name string) bool {
+		return name == "pg_catalog"
+	})
+	name := strings.Join(names, ".")
+	for range typ.GetArrayBounds() {
+		name += "[]"
+	}
+	return name
+}
+
+func stringValues(nodes []*pgquery.Node) []string {
+	var values []string
+	for _, node := range nodes {
+		if str := node.GetString_(); str != nil {
+			values = append(values, str.GetSval())
+		}
+	}
+	return values
+}
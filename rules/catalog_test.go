@@ -0,0 +1,172 @@
+package rules
+
+import (
+	"testing"
+
+	pgquery "github.com/pganalyze/pg_query_go/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustCatalog(t *testing.T, sql string) *Catalog {
+	t.Helper()
+
+	catalog := NewCatalog()
+	catalog.ApplyTree(mustParse(t, sql), "migration.sql")
+	return catalog
+}
+
+func TestCatalog(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should track created tables", func(t *testing.T) {
+		t.Parallel()
+
+		catalog := mustCatalog(t, "CREATE TABLE pgvet (id serial PRIMARY KEY, value text NOT NULL, parent_id int REFERENCES parent(id));")
+
+		table := catalog.Table(&pgquery.RangeVar{Relname: "pgvet"})
+		require.NotNil(t, table)
+		assert.Equal(t, "public", table.Schema)
+		assert.Equal(t, "migration.sql", table.File)
+		require.Len(t, table.Columns, 3)
+
+		id := table.Column("id")
+		require.NotNil(t, id)
+		assert.True(t, id.NotNull)
+		assert.True(t, id.HasDefault)
+		assert.True(t, table.Column("value").NotNull)
+		assert.False(t, table.Column("parent_id").NotNull)
+
+		assert.True(t, catalog.IsIndexed(&pgquery.RangeVar{Relname: "pgvet"}, "id"))
+		assert.False(t, catalog.IsIndexed(&pgquery.RangeVar{Relname: "pgvet"}, "parent_id"))
+		assert.Contains(t, catalog.Sequences, "public.pgvet_id_seq")
+	})
+
+	t.Run("Should apply ALTER TABLE", func(t *testing.T) {
+		t.Parallel()
+
+		catalog := mustCatalog(t, `
+CREATE TABLE pgvet (id int, value text);
+ALTER TABLE pgvet ADD COLUMN other int NOT NULL DEFAULT 0, DROP COLUMN value;
+ALTER TABLE pgvet ALTER COLUMN id SET NOT NULL, ALTER COLUMN id TYPE bigint;
+ALTER TABLE pgvet ADD CONSTRAINT other_check CHECK (other > 0) NOT VALID;
+`)
+
+		table := catalog.Table(&pgquery.RangeVar{Relname: "pgvet"})
+		require.NotNil(t, table)
+		assert.Nil(t, table.Column("value"))
+		require.NotNil(t, table.Column("other"))
+		assert.True(t, table.Column("other").HasDefault)
+		assert.True(t, table.Column("id").NotNull)
+		assert.Equal(t, "int8", table.Column("id").Type)
+
+		require.Len(t, table.Constraints, 1)
+		assert.Equal(t, "other_check", table.Constraints[0].Name)
+		assert.False(t, table.Constraints[0].Validated)
+	})
+
+	t.Run("Should apply renames and drops", func(t *testing.T) {
+		t.Parallel()
+
+		catalog := mustCatalog(t, `
+CREATE TABLE pgvet (id int, value text);
+CREATE INDEX pgvet_value_idx ON pgvet(value);
+ALTER TABLE pgvet RENAME COLUMN value TO new_value;
+ALTER TABLE pgvet RENAME TO renamed;
+CREATE TABLE dropped (id int);
+DROP TABLE dropped;
+`)
+
+		assert.Nil(t, catalog.Table(&pgquery.RangeVar{Relname: "pgvet"}))
+		assert.Nil(t, catalog.Table(&pgquery.RangeVar{Relname: "dropped"}))
+		table := catalog.Table(&pgquery.RangeVar{Relname: "renamed"})
+		require.NotNil(t, table)
+		assert.NotNil(t, table.Column("new_value"))
+		assert.True(t, catalog.IsIndexed(&pgquery.RangeVar{Relname: "renamed"}, "new_value"))
+	})
+
+	t.Run("Should track schemas and enums", func(t *testing.T) {
+		t.Parallel()
+
+		catalog := mustCatalog(t, `
+CREATE SCHEMA app CREATE TABLE pgvet (id int);
+CREATE TYPE app.mood AS ENUM ('sad', 'ok');
+ALTER TYPE app.mood ADD VALUE 'happy';
+`)
+
+		assert.True(t, catalog.Schemas["app"])
+		assert.NotNil(t, catalog.Table(&pgquery.RangeVar{Schemaname: "app", Relname: "pgvet"}))
+		assert.Nil(t, catalog.Table(&pgquery.RangeVar{Relname: "pgvet"}))
+		require.Contains(t, catalog.Enums, "app.mood")
+		assert.Equal(t, []string{"sad", "ok", "happy"}, catalog.Enums["app.mood"].Values)
+	})
+
+	t.Run("Should clone without sharing state", func(t *testing.T) {
+		t.Parallel()
+
+		catalog := mustCatalog(t, "CREATE TABLE pgvet (id int);")
+		clone := catalog.Clone()
+		clone.ApplyTree(mustParse(t, "ALTER TABLE pgvet ADD COLUMN value text;"), "2.sql")
+
+		assert.Nil(t, catalog.Table(&pgquery.RangeVar{Relname: "pgvet"}).Column("value"))
+		assert.NotNil(t, clone.Table(&pgquery.RangeVar{Relname: "pgvet"}).Column("value"))
+	})
+
+	t.Run("Should roll back to the savepoint", func(t *testing.T) {
+		t.Parallel()
+
+		catalog := mustCatalog(t, `
+CREATE TABLE pgvet (id int, value text);
+CREATE INDEX pgvet_value_idx ON pgvet(value);
+CREATE TYPE mood AS ENUM ('sad');
+`)
+		catalog.Savepoint()
+		catalog.ApplyTree(mustParse(t, `
+ALTER TABLE pgvet RENAME COLUMN value TO renamed;
+ALTER TABLE pgvet ALTER COLUMN id SET NOT NULL;
+ALTER TABLE pgvet RENAME TO other;
+ALTER TYPE mood ADD VALUE 'happy';
+CREATE TABLE created (id int);
+DROP INDEX pgvet_value_idx;
+`), "down.sql")
+		require.Nil(t, catalog.Table(&pgquery.RangeVar{Relname: "pgvet"}))
+		catalog.Rollback()
+
+		table := catalog.Table(&pgquery.RangeVar{Relname: "pgvet"})
+		require.NotNil(t, table)
+		assert.Equal(t, "pgvet", table.Name)
+		require.NotNil(t, table.Column("value"))
+		assert.False(t, table.Column("id").NotNull)
+		assert.NotContains(t, catalog.Tables, "public.other")
+		assert.NotContains(t, catalog.Tables, "public.created")
+		require.Contains(t, catalog.Indexes, "public.pgvet_value_idx")
+		assert.Equal(t, []string{"value"}, catalog.Indexes["public.pgvet_value_idx"].Columns)
+		assert.Equal(t, "public.pgvet", catalog.Indexes["public.pgvet_value_idx"].Table)
+		assert.Equal(t, []string{"sad"}, catalog.Enums["public.mood"].Values)
+
+		// Changes after the rollback are kept
+		catalog.ApplyTree(mustParse(t, "DROP TABLE pgvet;"), "2.sql")
+		assert.Nil(t, catalog.Table(&pgquery.RangeVar{Relname: "pgvet"}))
+	})
+}
+
+func TestCheckCatalog(t *testing.T) {
+	t.Parallel()
+
+	tree := mustParse(t, "CREATE TABLE pgvet (id int);\nALTER TABLE pgvet ADD COLUMN value text;")
+	ctx := &RuleContext{Rule: Rule{Code: testCode}}
+	var columns []int
+	ctx.Rule.Fn = func(ctx *RuleContext) error {
+		On(ctx, func(_ *Cursor, _ *pgquery.AlterTableStmt) {
+			columns = append(columns, len(ctx.Catalog.Table(&pgquery.RangeVar{Relname: "pgvet"}).Columns))
+		})
+		return nil
+	}
+
+	catalog := NewCatalog()
+	require.NoError(t, Check(tree, catalog, []*RuleContext{ctx}))
+
+	// The rules see the schema before the statement, the catalog is updated after
+	assert.Equal(t, []int{1}, columns)
+	assert.Len(t, catalog.Table(&pgquery.RangeVar{Relname: "pgvet"}).Columns, 2)
+}
@@ -0,0 +1,100 @@
+package rules
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	pgquery "github.com/pganalyze/pg_query_go/v6"
+)
+
+// Edit replaces Source[Start:End] with Text. Start and End are equal for insertions.
+type Edit struct {
+	Start int32
+	End   int32
+	Text  string
+}
+
+// FixContext is passed to the fixer of a rule for each statement the rule reported a violation for.
+type FixContext struct {
+	*RuleContext
+	// The contents of the file being fixed.
+	Source string
+	// The statement to fix.
+	Stmt *pgquery.RawStmt
+}
+
+// StmtEnd returns the end of the statement, excluding the terminating semicolon.
+func (c *FixContext) StmtEnd() int32 {
+	return stmtEnd(c.Source, c.Stmt)
+}
+
+// inTransaction reports whether the statement runs inside a transaction, replaying the statements before it like
+// the walk does.
+func (c *FixContext) inTransaction() bool {
+	tracker := txTracker{inTx: c.ImplicitTransaction}
+	for _, stmt := range c.Tree.GetStmts() {
+		if stmt.GetStmtLocation() >= c.Stmt.GetStmtLocation() {
+			break
+		}
+		tracker.apply(stmt.GetStmt())
+	}
+	return tracker.inTx
+}
+
+func (c *FixContext) tokens() []token {
+	return lex(c.Source, c.Stmt.GetStmtLocation(), c.StmtEnd())
+}
+
+// ApplyEdits applies the edits to the source. Edits that overlap an earlier edit are skipped, the number of applied
+// edits is returned. Insertions at the same position are applied in order.
+func ApplyEdits(src string, edits []Edit) (string, int, error) {
+	edits = slices.Clone(edits)
+	slices.SortStableFunc(edits, func(a, b Edit) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+
+	var b strings.Builder
+	var pos int32
+	var applied int
+	for _, edit := range edits {
+		if edit.Start > edit.End || int(edit.End) > len(src) {
+			return "", 0, fmt.Errorf("invalid edit %d-%d", edit.Start, edit.End)
+		}
+		if edit.Start < pos {
+			continue
+		}
+		b.WriteString(src[pos:edit.Start])
+		b.WriteString(edit.Text)
+		pos = edit.End
+		applied++
+	}
+	b.WriteString(src[pos:])
+	return b.String(), applied, nil
+}
+
+// insertAfter returns an edit inserting the text after the first token that is one of the keywords.
+func insertAfter(tokens []token, text string, keywords ...string) (Edit, bool) {
+	for _, tok := range tokens {
+		for _, keyword := range keywords {
+			if tok.is(keyword) {
+				return Edit{Start: tok.end, End: tok.end, Text: text}, true
+			}
+		}
+	}
+	return Edit{}, false
+}
+
+// nameAt returns the source text of the possibly qualified name starting at the location, e.g. public.pgvet.
+func (c *FixContext) nameAt(location int32) string {
+	tokens := lex(c.Source, location, c.StmtEnd())
+	if len(tokens) == 0 {
+		return ""
+	}
+	last := 0
+	for last+2 < len(tokens) && tokens[last+1].text == "." {
+		last += 2
+	}
+	return c.Source[location:tokens[last].end]
+}
@@ -0,0 +1,136 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustFix(t *testing.T, code Code, sql string) string {
+	t.Helper()
+
+	rule, ok := Lookup(code)
+	require.True(t, ok)
+	require.NotNil(t, rule.Fix)
+
+	tree := mustParse(t, sql)
+	ctx := &RuleContext{Rule: rule, Tree: tree}
+	var edits []Edit
+	for _, stmt := range tree.GetStmts() {
+		edits = append(edits, rule.Fix(&FixContext{RuleContext: ctx, Source: sql, Stmt: stmt})...)
+	}
+	fixed, _, err := ApplyEdits(sql, edits)
+	require.NoError(t, err)
+
+	// The fixed SQL must still be valid
+	mustParse(t, fixed)
+	return fixed
+}
+
+func TestFixers(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		code     Code
+		sql      string
+		expected string
+	}{
+		"create table": {
+			"missing-if-not-exists",
+			"-- comment\nCREATE TABLE public.pgvet (id int);",
+			"-- comment\nCREATE TABLE IF NOT EXISTS public.pgvet (id int);",
+		},
+		"create index": {
+			"missing-if-not-exists",
+			"CREATE UNIQUE INDEX CONCURRENTLY pgvet_idx ON pgvet (id);\nCREATE INDEX ON pgvet (id);",
+			"CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS pgvet_idx ON pgvet (id);\nCREATE INDEX ON pgvet (id);",
+		},
+		"add column": {
+			"missing-if-not-exists",
+			"ALTER TABLE pgvet ADD COLUMN value text, ADD other int;",
+			"ALTER TABLE pgvet ADD COLUMN IF NOT EXISTS value text, ADD IF NOT EXISTS other int;",
+		},
+		"drop index": {
+			"missing-if-exists",
+			"DROP INDEX CONCURRENTLY pgvet_idx;\ndrop materialized view pgvet_view;",
+			"DROP INDEX CONCURRENTLY IF EXISTS pgvet_idx;\ndrop materialized view IF EXISTS pgvet_view;",
+		},
+		"drop column": {
+			"missing-if-exists",
+			"ALTER TABLE pgvet DROP COLUMN value, DROP \"Other\", DROP CONSTRAINT value;",
+			"ALTER TABLE pgvet DROP COLUMN IF EXISTS value, DROP IF EXISTS \"Other\", DROP CONSTRAINT value;",
+		},
+		"non-concurrent index": {
+			"non-concurrent-index",
+			"CREATE INDEX /* index */ pgvet_idx ON pgvet (id);\nDROP INDEX pgvet_idx;\nREINDEX (VERBOSE) TABLE pgvet;",
+			"CREATE INDEX CONCURRENTLY /* index */ pgvet_idx ON pgvet (id);\nDROP INDEX CONCURRENTLY pgvet_idx;\nREINDEX (VERBOSE) TABLE CONCURRENTLY pgvet;",
+		},
+		"constraint": {
+			"constraint-excessive-lock",
+			"ALTER TABLE app.pgvet ADD CONSTRAINT reference_fk FOREIGN KEY (reference) REFERENCES parent(id);\n",
+			"ALTER TABLE app.pgvet ADD CONSTRAINT reference_fk FOREIGN KEY (reference) REFERENCES parent(id) NOT VALID;\n" +
+				"ALTER TABLE app.pgvet VALIDATE CONSTRAINT reference_fk;\n",
+		},
+		"multiple constraints": {
+			"constraint-excessive-lock",
+			"ALTER TABLE pgvet ADD CONSTRAINT value_check CHECK (value IN (1, 2)), ADD CONSTRAINT pgvet_pkey PRIMARY KEY (id)",
+			"ALTER TABLE pgvet ADD CONSTRAINT value_check CHECK (value IN (1, 2)) NOT VALID, ADD CONSTRAINT pgvet_pkey PRIMARY KEY (id);\n" +
+				"ALTER TABLE pgvet VALIDATE CONSTRAINT value_check",
+		},
+		"non-concurrent index in a transaction": {
+			"non-concurrent-index",
+			"BEGIN;\nCREATE INDEX pgvet_idx ON pgvet (id);\nREINDEX TABLE pgvet;\nCOMMIT;\nDROP INDEX pgvet_idx;",
+			"BEGIN;\nCREATE INDEX pgvet_idx ON pgvet (id);\nREINDEX TABLE pgvet;\nCOMMIT;\nDROP INDEX CONCURRENTLY pgvet_idx;",
+		},
+		"constraint in a transaction": {
+			"constraint-excessive-lock",
+			"BEGIN;\nALTER TABLE pgvet ADD CONSTRAINT reference_fk FOREIGN KEY (reference) REFERENCES parent(id);\nCOMMIT;\n",
+			"BEGIN;\nALTER TABLE pgvet ADD CONSTRAINT reference_fk FOREIGN KEY (reference) REFERENCES parent(id);\nCOMMIT;\n",
+		},
+		"timestamp": {
+			"use-timestamp-with-time-zone",
+			"CREATE TABLE pgvet (created timestamp, updated TIMESTAMP(3) WITHOUT TIME ZONE NOT NULL, deleted timestamptz);",
+			"CREATE TABLE pgvet (created timestamptz, updated timestamptz(3) NOT NULL, deleted timestamptz);",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, mustFix(t, tc.code, tc.sql))
+		})
+	}
+}
+
+func TestApplyEdits(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should apply edits in order", func(t *testing.T) {
+		t.Parallel()
+
+		fixed, applied, err := ApplyEdits("abcdef", []Edit{
+			{Start: 4, End: 6, Text: "X"},
+			{Start: 1, End: 1, Text: "1"},
+			{Start: 1, End: 1, Text: "2"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "a12bcdX", fixed)
+		assert.Equal(t, 3, applied)
+	})
+
+	t.Run("Should skip overlapping edits", func(t *testing.T) {
+		t.Parallel()
+
+		fixed, applied, err := ApplyEdits("abcdef", []Edit{{Start: 1, End: 4, Text: "X"}, {Start: 2, End: 3, Text: "Y"}})
+		require.NoError(t, err)
+		assert.Equal(t, "aXef", fixed)
+		assert.Equal(t, 1, applied)
+	})
+
+	t.Run("Should fail on invalid edits", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, err := ApplyEdits("abc", []Edit{{Start: 2, End: 10}})
+		assert.Error(t, err)
+	})
+}
@@ -1,6 +1,9 @@
 package rules
 
 import (
+	"fmt"
+	"strings"
+
 	pgquery "github.com/pganalyze/pg_query_go/v6"
 )
 
@@ -10,118 +13,168 @@ var idempotencyRules = []Rule{
 		Slug:     "Creating/altering a relation might fail if it already exists, making the migration non idempotent",
 		Help:     "Wrap the create statements with guards; e.g. CREATE TABLE IF NOT EXISTS pgvet ...",
 		Fn:       missingIfNotExists,
+		Fix:      fixMissingIfNotExists,
 		Category: idempotency,
+		Severity: SeverityWarning,
 	},
 	{
 		Code:     "missing-if-exists",
 		Slug:     "Dropping an object/relation might fail if it doesn't exist, making the migration non idempotent",
 		Help:     "Wrap the statements with guards; e.g. DROP INDEX CONCURRENTLY IF EXISTS pgvet_idx",
 		Fn:       missingIfExists,
+		Fix:      fixMissingIfExists,
 		Category: idempotency,
+		Severity: SeverityWarning,
 	},
 }
 
-func missingIfNotExists(
-	tree *pgquery.ParseResult,
-	code Code,
-	slug,
-	help string,
-	implicitTransaction bool,
-) ([]Result, error) {
-	var results []Result
-	for _, stmt := range tree.Stmts {
-		// Check relation creations
-		if createStmt := stmt.GetStmt().GetCreateStmt(); createStmt != nil {
-			if !createStmt.IfNotExists {
-				r := Result{
-					Slug:      slug,
-					Help:      help,
-					Code:      code,
-					StmtStart: stmt.GetStmtLocation(),
-					StmtEnd:   stmt.GetStmtLocation() + stmt.GetStmtLen(),
-				}
-				results = append(results, r)
-			}
+func missingIfNotExists(ctx *RuleContext) error {
+	// Check relation creations
+	On(ctx, func(c *Cursor, stmt *pgquery.CreateStmt) {
+		if !stmt.GetIfNotExists() {
+			objects := tableObjects(stmt.GetRelation())
+			ctx.Report(c, ctx.stmtSpan(c), objects, "Table "+objects.qualifiedTable()+" is created without IF NOT EXISTS")
 		}
+	})
 
-		// Check add columns
-		if alterTableStmt := stmt.GetStmt().GetAlterTableStmt(); alterTableStmt != nil {
-			for _, cmd := range alterTableStmt.GetCmds() {
-				isAddColumn := cmd.GetAlterTableCmd().GetSubtype() == pgquery.AlterTableType_AT_AddColumn
-				missingIfNotExists := cmd.GetAlterTableCmd().GetMissingOk()
-				if isAddColumn && !missingIfNotExists {
-					r := Result{
-						Slug:      slug,
-						Help:      help,
-						Code:      code,
-						StmtStart: stmt.GetStmtLocation(),
-						StmtEnd:   stmt.GetStmtLocation() + stmt.GetStmtLen(),
-					}
-					results = append(results, r)
-				}
-			}
+	// Check add columns
+	On(ctx, func(c *Cursor, cmd *pgquery.AlterTableCmd) {
+		isAddColumn := cmd.GetSubtype() == pgquery.AlterTableType_AT_AddColumn
+		if isAddColumn && !cmd.GetMissingOk() {
+			objects := alteredTable(c)
+			objects.Column = cmd.GetDef().GetColumnDef().GetColname()
+			ctx.Report(c, ctx.cmdSpan(c, cmd), objects, "Column "+objects.qualifiedColumn()+" is added without IF NOT EXISTS")
 		}
+	})
 
-		// Check index creations
-		if indexStmt := stmt.GetStmt().GetIndexStmt(); indexStmt != nil {
-			isNamedIndex := indexStmt.Idxname != ""
-			if !indexStmt.IfNotExists && isNamedIndex {
-				r := Result{
-					Slug:      slug,
-					Help:      help,
-					Code:      code,
-					StmtStart: stmt.GetStmtLocation(),
-					StmtEnd:   stmt.GetStmtLocation() + stmt.GetStmtLen(),
-				}
-				results = append(results, r)
+	// Check index creations
+	On(ctx, func(c *Cursor, stmt *pgquery.IndexStmt) {
+		isNamedIndex := stmt.GetIdxname() != ""
+		if !stmt.GetIfNotExists() && isNamedIndex {
+			objects := tableObjects(stmt.GetRelation())
+			objects.Index = stmt.GetIdxname()
+			ctx.Report(c, ctx.stmtSpan(c), objects, indexName(objects)+" is created without IF NOT EXISTS")
+		}
+	})
+	return nil
+}
+
+func missingIfExists(ctx *RuleContext) error {
+	// Check drop relations, e.g. DROP TABLE, DROP INDEX
+	On(ctx, func(c *Cursor, stmt *pgquery.DropStmt) {
+		if !stmt.GetMissingOk() {
+			objects, names := droppedObjects(stmt)
+			message := fmt.Sprintf("Dropping %s %s without IF EXISTS", objectTypeName(stmt.GetRemoveType()), strings.Join(names, ", "))
+			ctx.Report(c, ctx.stmtSpan(c), objects, message)
+		}
+	})
+
+	// Check drop columns
+	On(ctx, func(c *Cursor, cmd *pgquery.AlterTableCmd) {
+		isDropColumn := cmd.GetSubtype() == pgquery.AlterTableType_AT_DropColumn
+		if isDropColumn && !cmd.GetMissingOk() {
+			objects := alteredTable(c)
+			objects.Column = cmd.GetName()
+			ctx.Report(c, ctx.cmdSpan(c, cmd), objects, "Dropping column "+objects.qualifiedColumn()+" without IF EXISTS")
+		}
+	})
+	return nil
+}
+
+func fixMissingIfNotExists(ctx *FixContext) []Edit {
+	var edits []Edit
+	switch stmt := ctx.Stmt.GetStmt().GetNode().(type) {
+	case *pgquery.Node_CreateStmt:
+		if !stmt.CreateStmt.GetIfNotExists() {
+			location := stmt.CreateStmt.GetRelation().GetLocation()
+			edits = append(edits, Edit{Start: location, End: location, Text: "IF NOT EXISTS "})
+		}
+	case *pgquery.Node_IndexStmt:
+		if stmt.IndexStmt.GetIfNotExists() || stmt.IndexStmt.GetIdxname() == "" {
+			break
+		}
+		keyword := "index"
+		if stmt.IndexStmt.GetConcurrent() {
+			keyword = "concurrently"
+		}
+		if edit, ok := insertAfter(ctx.tokens(), " IF NOT EXISTS", keyword); ok {
+			edits = append(edits, edit)
+		}
+	case *pgquery.Node_AlterTableStmt:
+		for _, cmd := range stmt.AlterTableStmt.GetCmds() {
+			cmd := cmd.GetAlterTableCmd()
+			if cmd.GetSubtype() == pgquery.AlterTableType_AT_AddColumn && !cmd.GetMissingOk() {
+				location := cmd.GetDef().GetColumnDef().GetLocation()
+				edits = append(edits, Edit{Start: location, End: location, Text: "IF NOT EXISTS "})
 			}
 		}
 	}
+	return edits
+}
 
-	return results, nil
+// The keywords following DROP for the object types that can be fixed.
+var dropKeywords = map[pgquery.ObjectType][]string{
+	pgquery.ObjectType_OBJECT_TABLE:         {"table"},
+	pgquery.ObjectType_OBJECT_INDEX:         {"index"},
+	pgquery.ObjectType_OBJECT_VIEW:          {"view"},
+	pgquery.ObjectType_OBJECT_MATVIEW:       {"materialized", "view"},
+	pgquery.ObjectType_OBJECT_FOREIGN_TABLE: {"foreign", "table"},
+	pgquery.ObjectType_OBJECT_SEQUENCE:      {"sequence"},
+	pgquery.ObjectType_OBJECT_TYPE:          {"type"},
+	pgquery.ObjectType_OBJECT_DOMAIN:        {"domain"},
+	pgquery.ObjectType_OBJECT_SCHEMA:        {"schema"},
+	pgquery.ObjectType_OBJECT_EXTENSION:     {"extension"},
+	pgquery.ObjectType_OBJECT_FUNCTION:      {"function"},
+	pgquery.ObjectType_OBJECT_PROCEDURE:     {"procedure"},
+	pgquery.ObjectType_OBJECT_TRIGGER:       {"trigger"},
 }
 
-func missingIfExists(
-	tree *pgquery.ParseResult,
-	code Code,
-	slug,
-	help string,
-	implicitTransaction bool,
-) ([]Result, error) {
-	var results []Result
-	for _, stmt := range tree.Stmts {
-		// Check drop relations, e.g. DROP TABLE, DROP INDEX
-		if dropStmt := stmt.GetStmt().GetDropStmt(); dropStmt != nil {
-			if !dropStmt.MissingOk {
-				r := Result{
-					Slug:      slug,
-					Help:      help,
-					Code:      code,
-					StmtStart: stmt.GetStmtLocation(),
-					StmtEnd:   stmt.GetStmtLocation() + stmt.GetStmtLen(),
-				}
-				results = append(results, r)
+// objectTypeName returns the name of the object type as used in SQL, e.g. materialized view.
+func objectTypeName(typ pgquery.ObjectType) string {
+	if keywords, ok := dropKeywords[typ]; ok {
+		return strings.Join(keywords, " ")
+	}
+	return "object"
+}
+
+func fixMissingIfExists(ctx *FixContext) []Edit {
+	tokens := ctx.tokens()
+
+	var edits []Edit
+	switch stmt := ctx.Stmt.GetStmt().GetNode().(type) {
+	case *pgquery.Node_DropStmt:
+		keywords, ok := dropKeywords[stmt.DropStmt.GetRemoveType()]
+		if stmt.DropStmt.GetMissingOk() || !ok || len(tokens) <= len(keywords) || !tokens[0].is("drop") {
+			break
+		}
+		// The guard goes after DROP and the object type, e.g. DROP INDEX CONCURRENTLY IF EXISTS
+		last := tokens[len(keywords)]
+		if stmt.DropStmt.GetConcurrent() {
+			last = tokens[len(keywords)+1]
+		}
+		edits = append(edits, Edit{Start: last.end, End: last.end, Text: " IF EXISTS"})
+	case *pgquery.Node_AlterTableStmt:
+		var columns []string
+		for _, cmd := range stmt.AlterTableStmt.GetCmds() {
+			cmd := cmd.GetAlterTableCmd()
+			if cmd.GetSubtype() == pgquery.AlterTableType_AT_DropColumn && !cmd.GetMissingOk() {
+				columns = append(columns, cmd.GetName())
 			}
 		}
-
-		// Check drop columns
-		if alterTableStmt := stmt.GetStmt().GetAlterTableStmt(); alterTableStmt != nil {
-			for _, cmd := range alterTableStmt.GetCmds() {
-				isDropColumn := cmd.GetAlterTableCmd().GetSubtype() == pgquery.AlterTableType_AT_DropColumn
-				missingIfExists := cmd.GetAlterTableCmd().GetMissingOk()
-				if isDropColumn && !missingIfExists {
-					r := Result{
-						Slug:      slug,
-						Help:      help,
-						Code:      code,
-						StmtStart: stmt.GetStmtLocation(),
-						StmtEnd:   stmt.GetStmtLocation() + stmt.GetStmtLen(),
-					}
-					results = append(results, r)
-				}
+		// Find DROP [COLUMN] <name> for each of the columns
+		for i := 0; i < len(tokens) && len(columns) > 0; i++ {
+			if !tokens[i].is("drop") {
+				continue
+			}
+			j := i + 1
+			if j < len(tokens) && tokens[j].is("column") {
+				j++
+			}
+			if j < len(tokens) && tokens[j].ident() == columns[0] {
+				edits = append(edits, Edit{Start: tokens[j-1].end, End: tokens[j-1].end, Text: " IF EXISTS"})
+				columns = columns[1:]
 			}
 		}
 	}
-
-	return results, nil
+	return edits
 }
@@ -0,0 +1,164 @@
+package rules
+
+import (
+	"strings"
+)
+
+// token is a word, quoted identifier, literal or punctuation character in the SQL source.
+type token struct {
+	text  string
+	start int32
+	end   int32
+}
+
+// is reports whether the token is the given keyword, ignoring case.
+func (t token) is(keyword string) bool {
+	return strings.EqualFold(t.text, keyword)
+}
+
+// ident returns the identifier the token refers to.
+func (t token) ident() string {
+	if strings.HasPrefix(t.text, `"`) {
+		return strings.ReplaceAll(t.text[1:len(t.text)-1], `""`, `"`)
+	}
+	return strings.ToLower(t.text)
+}
+
+// lex splits src[start:end] into tokens, leaving out whitespace and comments.
+// It only knows enough SQL to find keywords and punctuation, the parser has already validated the statement.
+func lex(src string, start, end int32) []token {
+	var tokens []token
+	i := int(start)
+	for i < int(end) {
+		ch := src[i]
+		next := byte(0)
+		if i+1 < int(end) {
+			next = src[i+1]
+		}
+
+		var j int
+		switch {
+		case isSpace(ch):
+			i++
+			continue
+		case ch == '-' && next == '-':
+			for i < int(end) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case ch == '/' && next == '*':
+			i = skipBlockComment(src, i, int(end))
+			continue
+		case ch == '\'':
+			escapes := i > int(start) && (src[i-1] == 'e' || src[i-1] == 'E')
+			j = skipQuoted(src, i, int(end), '\'', escapes)
+		case ch == '"':
+			j = skipQuoted(src, i, int(end), '"', false)
+		case ch == '$' && (next == '$' || isIdentStart(next)):
+			j = skipDollarQuoted(src, i, int(end))
+		case isIdentChar(ch):
+			j = i
+			for j < int(end) && isIdentChar(src[j]) {
+				j++
+			}
+			// E'...' strings belong to the following literal
+			if j-i == 1 && (ch == 'e' || ch == 'E') && j < int(end) && src[j] == '\'' {
+				j = skipQuoted(src, j, int(end), '\'', true)
+			}
+		default:
+			j = i + 1
+		}
+
+		tokens = append(tokens, token{text: src[i:j], start: int32(i), end: int32(j)})
+		i = j
+	}
+	return tokens
+}
+
+// NormalizeSQL returns the SQL without comments, with single spaces between the tokens and words in lower case, so
+// that formatting changes don't change the result. Literals and quoted identifiers are kept as is.
+func NormalizeSQL(sql string) string {
+	tokens := lex(sql, 0, int32(len(sql)))
+	words := make([]string, len(tokens))
+	for i, tok := range tokens {
+		switch tok.text[0] {
+		case '\'', '"', '$':
+			words[i] = tok.text
+		default:
+			// E'...' literals keep their content
+			if len(tok.text) > 1 && tok.text[1] == '\'' {
+				words[i] = tok.text
+				continue
+			}
+			words[i] = strings.ToLower(tok.text)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func skipBlockComment(src string, i, end int) int {
+	depth := 0
+	for i < end {
+		switch {
+		case strings.HasPrefix(src[i:end], "/*"):
+			depth++
+			i += 2
+		case strings.HasPrefix(src[i:end], "*/"):
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return end
+}
+
+// skipQuoted returns the position after the closing quote, doubled quotes are part of the content.
+func skipQuoted(src string, i, end int, quote byte, escapes bool) int {
+	for i++; i < end; i++ {
+		switch src[i] {
+		case '\\':
+			if escapes {
+				i++
+			}
+		case quote:
+			if i+1 < end && src[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return end
+}
+
+// skipDollarQuoted returns the position after a $tag$...$tag$ string, or after the $ if it doesn't start one.
+func skipDollarQuoted(src string, i, end int) int {
+	j := i + 1
+	for j < end && src[j] != '$' && isIdentChar(src[j]) {
+		j++
+	}
+	if j >= end || src[j] != '$' {
+		return i + 1
+	}
+	tag := src[i : j+1]
+	if k := strings.Index(src[j+1:end], tag); k >= 0 {
+		return j + 1 + k + len(tag)
+	}
+	return end
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\f' || ch == '\v'
+}
+
+func isIdentStart(ch byte) bool {
+	return ch == '_' || ch >= 0x80 || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+func isIdentChar(ch byte) bool {
+	return isIdentStart(ch) || ch == '$' || (ch >= '0' && ch <= '9')
+}
@@ -0,0 +1,34 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLex(t *testing.T) {
+	t.Parallel()
+
+	src := "-- comment\nSELECT /* a /* nested */ comment */ \"Col\"\"x\", 'it''s', E'\\'', $tag$ $$ ; $tag$, x::int;"
+
+	var texts []string
+	for _, tok := range lex(src, 0, int32(len(src))) {
+		texts = append(texts, tok.text)
+		assert.Equal(t, tok.text, src[tok.start:tok.end])
+	}
+	expected := []string{
+		"SELECT", `"Col""x"`, ",", "'it''s'", ",", `E'\''`, ",", "$tag$ $$ ; $tag$", ",", "x", ":", ":", "int", ";",
+	}
+	assert.Equal(t, expected, texts)
+	assert.Equal(t, `Col"x`, token{text: `"Col""x"`}.ident())
+	assert.Equal(t, "col", token{text: "COL"}.ident())
+}
+
+func TestNormalizeSQL(t *testing.T) {
+	t.Parallel()
+
+	expected := `alter table "Pgvet" add column name text default 'A  B'`
+	assert.Equal(t, expected, NormalizeSQL(`ALTER TABLE "Pgvet" ADD COLUMN name text DEFAULT 'A  B'`))
+	assert.Equal(t, expected, NormalizeSQL("-- A comment\nALTER  TABLE \"Pgvet\"\n\tADD COLUMN /* inline */ name TEXT DEFAULT 'A  B'"))
+	assert.Equal(t, "select E'X' , $$ A $$", NormalizeSQL("SELECT E'X', $$ A $$"))
+}
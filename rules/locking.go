@@ -1,6 +1,10 @@
 package rules
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	pgquery "github.com/pganalyze/pg_query_go/v6"
 )
 
@@ -10,14 +14,34 @@ var lockingRules = []Rule{
 		Slug:     "Creating/dropping an index non-concurrently acquires a lock on the table that block writes for the duration of the operation",
 		Help:     "Create/drop the index concurrently using the `CONCURRENTLY` option to avoid blocking. Note: this cannot be done inside a transaction",
 		Fn:       nonConcurrentIndex,
+		Fix:      fixNonConcurrentIndex,
 		Category: locking,
+		Severity: SeverityError,
 	},
 	{
 		Code:     "constraint-excessive-lock",
 		Slug:     "Adding a constraint acquires a lock blocking any writes during the constraint validation",
 		Help:     "Append the `NOT VALID` option and then in a following transaction perform `ALTER TABLE VALIDATE CONSTRAINT ...`",
 		Fn:       constraintExcessiveLock,
+		Fix:      fixConstraintExcessiveLock,
+		Category: locking,
+		Severity: SeverityError,
+	},
+	{
+		Code:     "add-column-with-default",
+		Slug:     "Before PostgreSQL 11 adding a column with a default rewrites the table while holding a lock that blocks reads and writes",
+		Help:     "Add the column without a default, then set the default and backfill the existing rows in separate statements",
+		Fn:       addColumnWithDefault,
+		Category: locking,
+		Severity: SeverityError,
+	},
+	{
+		Code:     "non-concurrent-detach-partition",
+		Slug:     "Detaching a partition non-concurrently acquires a lock on the parent table that blocks reads and writes",
+		Help:     "Detach the partition using the `CONCURRENTLY` option to avoid blocking. Note: this cannot be done inside a transaction",
+		Fn:       nonConcurrentDetachPartition,
 		Category: locking,
+		Severity: SeverityError,
 	},
 	{
 		Code:              "multiple-locks",
@@ -25,153 +49,257 @@ var lockingRules = []Rule{
 		Help:              "Perform the changes in separate transactions",
 		Fn:                multipleLocks,
 		Category:          locking,
+		Severity:          SeverityWarning,
 		DisabledByDefault: true,
+		Options:           &MultipleLocksOptions{MaxLocks: 1},
 	},
 }
 
-func nonConcurrentIndex(
-	tree *pgquery.ParseResult,
-	code Code,
-	slug,
-	help string,
-	implicitTransaction bool,
-) ([]Result, error) {
-	var results []Result
-	for _, stmt := range tree.Stmts {
-		// Check for index creation
-		if indexStmt := stmt.GetStmt().GetIndexStmt(); indexStmt != nil {
-			if !indexStmt.Concurrent {
-				r := Result{
-					Slug:      slug,
-					Help:      help,
-					Code:      code,
-					StmtStart: stmt.GetStmtLocation(),
-					StmtEnd:   stmt.GetStmtLocation() + stmt.GetStmtLen(),
-				}
-				results = append(results, r)
-			}
+// MultipleLocksOptions are the options of the multiple-locks rule.
+type MultipleLocksOptions struct {
+	// The number of tables that can be locked in a single transaction before it's a violation.
+	MaxLocks int `yaml:"maxLocks"`
+}
+
+func (o *MultipleLocksOptions) Validate() error {
+	if o.MaxLocks < 1 {
+		return errors.New("maxLocks must be at least 1")
+	}
+	return nil
+}
+
+func nonConcurrentIndex(ctx *RuleContext) error {
+	// Check for index creation
+	On(ctx, func(c *Cursor, stmt *pgquery.IndexStmt) {
+		if !stmt.GetConcurrent() && !isNewTable(ctx, stmt.GetRelation()) {
+			objects := tableObjects(stmt.GetRelation())
+			objects.Index = stmt.GetIdxname()
+			ctx.Report(c, ctx.stmtSpan(c), objects, indexName(objects)+" is created without CONCURRENTLY")
 		}
-		// Check for index creation
-		if dropStmt := stmt.GetStmt().GetDropStmt(); dropStmt != nil {
-			if dropStmt.Concurrent {
-				continue
+	})
+	// Check for reindexing, which can only be done concurrently from PostgreSQL 12
+	On(ctx, func(c *Cursor, stmt *pgquery.ReindexStmt) {
+		if !ctx.versionAtLeast(12) || isConcurrentReindex(stmt) {
+			return
+		}
+		if stmt.GetKind() == pgquery.ReindexObjectType_REINDEX_OBJECT_TABLE && isNewTable(ctx, stmt.GetRelation()) {
+			return
+		}
+		objects, target := reindexTarget(stmt)
+		ctx.Report(c, ctx.stmtSpan(c), objects, "Reindexing "+target+" without CONCURRENTLY")
+	})
+	// Check for index drop
+	On(ctx, func(c *Cursor, stmt *pgquery.DropStmt) {
+		if stmt.GetRemoveType() != pgquery.ObjectType_OBJECT_INDEX || stmt.GetConcurrent() {
+			return
+		}
+		for _, object := range stmt.GetObjects() {
+			var table *Table
+			if index := ctx.Catalog.Indexes[qualify(splitName(object.GetList().GetItems()))]; index != nil {
+				table = ctx.Catalog.Tables[index.Table]
 			}
-			if dropStmt.GetRemoveType() == pgquery.ObjectType_OBJECT_INDEX {
-				r := Result{
-					Slug:      slug,
-					Help:      help,
-					Code:      code,
-					StmtStart: stmt.GetStmtLocation(),
-					StmtEnd:   stmt.GetStmtLocation() + stmt.GetStmtLen(),
+			if table == nil || !table.New {
+				objects, names := droppedObjects(stmt)
+				if table != nil {
+					objects.Table = table.Name
 				}
-				results = append(results, r)
+				ctx.Report(c, ctx.stmtSpan(c), objects, "Index "+strings.Join(names, ", ")+" is dropped without CONCURRENTLY")
+				return
 			}
 		}
-	}
-
-	return results, nil
+	})
+	return nil
 }
 
-func constraintExcessiveLock(
-	tree *pgquery.ParseResult,
-	code Code,
-	slug,
-	help string,
-	implicitTransaction bool,
-) ([]Result, error) {
-	var results []Result
-	for _, decl := range FilterStatements[*pgquery.Node_AlterTableStmt](tree.Stmts) {
-		for _, cmd := range decl.Stmt.AlterTableStmt.GetCmds() {
-			alterTableCmd := cmd.GetAlterTableCmd()
-
-			isAddConstraint := alterTableCmd.GetSubtype() == pgquery.AlterTableType_AT_AddConstraint
-			isInitiallyValid := alterTableCmd.GetDef().GetConstraint().GetInitiallyValid() // maps to NOT VALID
-
-			if isAddConstraint && isInitiallyValid {
-				r := Result{
-					Slug:      slug,
-					Help:      help,
-					Code:      code,
-					StmtStart: decl.Start,
-					StmtEnd:   decl.End,
-				}
-				results = append(results, r)
+func constraintExcessiveLock(ctx *RuleContext) error {
+	On(ctx, func(c *Cursor, cmd *pgquery.AlterTableCmd) {
+		isAddConstraint := cmd.GetSubtype() == pgquery.AlterTableType_AT_AddConstraint
+		isInitiallyValid := cmd.GetDef().GetConstraint().GetInitiallyValid() // maps to NOT VALID
+
+		if isAddConstraint && isInitiallyValid && !isAlteringNewTable(ctx, c) {
+			objects := alteredTable(c)
+			objects.Constraint = cmd.GetDef().GetConstraint().GetConname()
+			message := fmt.Sprintf("Constraint %s is added to table %s without NOT VALID", objects.Constraint, objects.qualifiedTable())
+			if objects.Constraint == "" {
+				message = fmt.Sprintf("A constraint is added to table %s without NOT VALID", objects.qualifiedTable())
 			}
+			ctx.Report(c, ctx.cmdSpan(c, cmd), objects, message)
 		}
+	})
+	return nil
+}
+
+func fixNonConcurrentIndex(ctx *FixContext) []Edit {
+	// CONCURRENTLY fails inside a transaction
+	if ctx.inTransaction() {
+		return nil
 	}
 
-	return results, nil
+	var keyword string
+	switch stmt := ctx.Stmt.GetStmt().GetNode().(type) {
+	case *pgquery.Node_IndexStmt:
+		if !stmt.IndexStmt.GetConcurrent() {
+			keyword = "index"
+		}
+	case *pgquery.Node_DropStmt:
+		if stmt.DropStmt.GetRemoveType() == pgquery.ObjectType_OBJECT_INDEX && !stmt.DropStmt.GetConcurrent() {
+			keyword = "index"
+		}
+	case *pgquery.Node_ReindexStmt:
+		if isConcurrentReindex(stmt.ReindexStmt) {
+			break
+		}
+		switch stmt.ReindexStmt.GetKind() {
+		case pgquery.ReindexObjectType_REINDEX_OBJECT_INDEX:
+			keyword = "index"
+		case pgquery.ReindexObjectType_REINDEX_OBJECT_TABLE:
+			keyword = "table"
+		case pgquery.ReindexObjectType_REINDEX_OBJECT_SCHEMA:
+			keyword = "schema"
+		case pgquery.ReindexObjectType_REINDEX_OBJECT_DATABASE:
+			keyword = "database"
+		}
+	}
+	if keyword == "" {
+		return nil
+	}
+
+	if edit, ok := insertAfter(ctx.tokens(), " CONCURRENTLY", keyword); ok {
+		return []Edit{edit}
+	}
+	return nil
 }
 
-func multipleLocks(
-	tree *pgquery.ParseResult,
-	code Code,
-	slug,
-	help string,
-	implicitTransaction bool,
-) ([]Result, error) {
-	var results []Result
-
-	tracker := newTXTracker(implicitTransaction)
-
-	for _, stmt := range tree.Stmts {
-		// Check for alter table
-		if alterTableStmt := stmt.GetStmt().GetAlterTableStmt(); alterTableStmt != nil {
-			if tracker.add(alterTableStmt.GetRelation().GetRelname()) > 1 {
-				r := Result{
-					Slug:      slug,
-					Help:      help,
-					Code:      code,
-					StmtStart: stmt.GetStmtLocation(),
-					StmtEnd:   stmt.GetStmtLocation() + stmt.GetStmtLen(),
-				}
-				results = append(results, r)
-			}
+func fixConstraintExcessiveLock(ctx *FixContext) []Edit {
+	// The validation must run in a following transaction, validating in the same one keeps the lock of the ADD
+	stmt := ctx.Stmt.GetStmt().GetAlterTableStmt()
+	if stmt == nil || ctx.inTransaction() {
+		return nil
+	}
+
+	var edits []Edit
+	var validations strings.Builder
+	for _, cmd := range stmt.GetCmds() {
+		cmd := cmd.GetAlterTableCmd()
+		constraint := cmd.GetDef().GetConstraint()
+		if cmd.GetSubtype() != pgquery.AlterTableType_AT_AddConstraint || !constraint.GetInitiallyValid() {
+			continue
+		}
+		// Only foreign key and check constraints can be added as NOT VALID, and the constraint must be named to be
+		// validated afterwards
+		isForeignKey := constraint.GetContype() == pgquery.ConstrType_CONSTR_FOREIGN
+		isCheck := constraint.GetContype() == pgquery.ConstrType_CONSTR_CHECK
+		tokens := lex(ctx.Source, constraint.GetLocation(), ctx.StmtEnd())
+		if !(isForeignKey || isCheck) || len(tokens) < 2 || !tokens[0].is("constraint") {
+			continue
 		}
 
-		// Check for new tx or commit
-		if txStmt := stmt.GetStmt().GetTransactionStmt(); txStmt != nil {
-			switch txStmt.GetKind() {
-			case pgquery.TransactionStmtKind_TRANS_STMT_COMMIT:
-				tracker.commitTx()
-			case pgquery.TransactionStmtKind_TRANS_STMT_BEGIN, pgquery.TransactionStmtKind_TRANS_STMT_START:
-				tracker.beginTx()
+		end := clauseEnd(tokens)
+		edits = append(edits, Edit{Start: end, End: end, Text: " NOT VALID"})
+		fmt.Fprintf(&validations, ";\nALTER TABLE %s VALIDATE CONSTRAINT %s", ctx.nameAt(stmt.GetRelation().GetLocation()), tokens[1].text)
+	}
+
+	// The statement's own semicolon terminates the last validation
+	if validations.Len() > 0 {
+		edits = append(edits, Edit{Start: ctx.StmtEnd(), End: ctx.StmtEnd(), Text: validations.String()})
+	}
+	return edits
+}
+
+func addColumnWithDefault(ctx *RuleContext) error {
+	if ctx.versionAtLeast(11) {
+		return nil
+	}
+
+	On(ctx, func(c *Cursor, cmd *pgquery.AlterTableCmd) {
+		if cmd.GetSubtype() != pgquery.AlterTableType_AT_AddColumn || isAlteringNewTable(ctx, c) {
+			return
+		}
+		for _, constraint := range cmd.GetDef().GetColumnDef().GetConstraints() {
+			if constraint.GetConstraint().GetContype() == pgquery.ConstrType_CONSTR_DEFAULT {
+				objects := alteredTable(c)
+				objects.Column = cmd.GetDef().GetColumnDef().GetColname()
+				ctx.Report(c, ctx.cmdSpan(c, cmd), objects, fmt.Sprintf("Adding column %s with a default", objects.qualifiedColumn()))
+				return
 			}
 		}
+	})
+	return nil
+}
+
+func nonConcurrentDetachPartition(ctx *RuleContext) error {
+	// DETACH PARTITION CONCURRENTLY was added in PostgreSQL 14
+	if !ctx.versionAtLeast(14) {
+		return nil
 	}
 
-	return results, nil
+	On(ctx, func(c *Cursor, cmd *pgquery.AlterTableCmd) {
+		isDetach := cmd.GetSubtype() == pgquery.AlterTableType_AT_DetachPartition
+		if isDetach && !cmd.GetDef().GetPartitionCmd().GetConcurrent() && !isAlteringNewTable(ctx, c) {
+			objects := alteredTable(c)
+			partition := tableObjects(cmd.GetDef().GetPartitionCmd().GetName())
+			message := fmt.Sprintf("Partition %s is detached from table %s without CONCURRENTLY", partition.qualifiedTable(), objects.qualifiedTable())
+			ctx.Report(c, ctx.cmdSpan(c, cmd), objects, message)
+		}
+	})
+	return nil
 }
 
-type txTracker struct {
-	tableChanges map[string]bool
-	inTx         bool
+// indexName returns how to refer to the index in messages, e.g. "Index pgvet_idx" or "Index on public.pgvet" if it
+// isn't named.
+func indexName(objects Objects) string {
+	if objects.Index == "" {
+		return "Index on " + objects.qualifiedTable()
+	}
+	return "Index " + objects.Index
 }
 
-func newTXTracker(startInTx bool) *txTracker {
-	tracker := &txTracker{
-		tableChanges: map[string]bool{},
-		inTx:         startInTx, // Assume that we start in a transaction
+// reindexTarget returns the objects of a REINDEX and how to refer to them in messages, e.g. "table public.pgvet".
+func reindexTarget(stmt *pgquery.ReindexStmt) (Objects, string) {
+	switch stmt.GetKind() {
+	case pgquery.ReindexObjectType_REINDEX_OBJECT_TABLE:
+		objects := tableObjects(stmt.GetRelation())
+		return objects, "table " + objects.qualifiedTable()
+	case pgquery.ReindexObjectType_REINDEX_OBJECT_INDEX:
+		objects := Objects{Schema: schemaOr(stmt.GetRelation().GetSchemaname(), ""), Index: stmt.GetRelation().GetRelname()}
+		return objects, "index " + objects.Schema + "." + objects.Index
+	case pgquery.ReindexObjectType_REINDEX_OBJECT_SCHEMA:
+		return Objects{Schema: stmt.GetName()}, "schema " + stmt.GetName()
+	default:
+		return Objects{}, "database " + stmt.GetName()
 	}
-	return tracker
 }
 
-// Returns the number of tables in the current tx after the add
-func (t *txTracker) add(tableName string) int {
-	if !t.inTx {
-		return 0
+// isConcurrentReindex reports whether the REINDEX has the CONCURRENTLY option.
+func isConcurrentReindex(stmt *pgquery.ReindexStmt) bool {
+	for _, param := range stmt.GetParams() {
+		if param.GetDefElem().GetDefname() == "concurrently" {
+			return true
+		}
 	}
-	t.tableChanges[tableName] = true
-	return len(t.tableChanges)
+	return false
 }
 
-func (t *txTracker) beginTx() {
-	// If we're already in a TX this is a no-op with a warning
-	t.inTx = true
-}
+func multipleLocks(ctx *RuleContext) error {
+	opts := optionsOf[*MultipleLocksOptions](ctx)
+	// The tables locked in the current transaction
+	locked := map[string]bool{}
 
-func (t *txTracker) commitTx() {
-	// If we're not in a TX this is a no-op with a warning
-	t.tableChanges = map[string]bool{}
-	t.inTx = false
+	On(ctx, func(c *Cursor, stmt *pgquery.AlterTableStmt) {
+		if !ctx.inTransaction() {
+			return
+		}
+		locked[stmt.GetRelation().GetRelname()] = true
+		if locks := len(locked); locks > opts.MaxLocks {
+			objects := tableObjects(stmt.GetRelation())
+			message := fmt.Sprintf("Altering table %s makes it %d tables locked in the same transaction", objects.qualifiedTable(), locks)
+			ctx.Report(c, ctx.stmtSpan(c), objects, message)
+		}
+	})
+	On(ctx, func(_ *Cursor, stmt *pgquery.TransactionStmt) {
+		if _, ok := transactionBoundary(stmt); ok {
+			clear(locked)
+		}
+	})
+	return nil
 }
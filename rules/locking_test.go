@@ -17,7 +17,7 @@ func TestNonConcurrentIndex(t *testing.T) {
 		tree := mustParse(t, "CREATE INDEX ON pgvet (id);")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := nonConcurrentIndex(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, nonConcurrentIndex, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 1)
 
@@ -34,7 +34,7 @@ func TestNonConcurrentIndex(t *testing.T) {
 		tree := mustParse(t, "DROP INDEX pgvet_idx;")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := nonConcurrentIndex(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, nonConcurrentIndex, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 1)
 
@@ -56,7 +56,7 @@ func TestNonConcurrentIndex(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 4)
 
-		res, err := nonConcurrentIndex(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, nonConcurrentIndex, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 2)
 
@@ -75,7 +75,7 @@ func TestNonConcurrentIndex(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 4)
 
-		res, err := nonConcurrentIndex(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, nonConcurrentIndex, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		assert.Empty(t, res)
 	})
@@ -86,10 +86,27 @@ func TestNonConcurrentIndex(t *testing.T) {
 		tree := mustParse(t, "CREATE INDEX CONCURRENTLY ON pgvet (id);")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := nonConcurrentIndex(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, nonConcurrentIndex, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		assert.Empty(t, res)
 	})
+
+	t.Run("Should not flag tables created in the same file", func(t *testing.T) {
+		t.Parallel()
+
+		var b strings.Builder
+		b.WriteString("CREATE TABLE pgvet (id text PRIMARY KEY, value text);\n")
+		b.WriteString("CREATE INDEX pgvet_value_idx ON pgvet (value);\n")
+		b.WriteString("DROP INDEX pgvet_value_idx;\n")
+		b.WriteString("CREATE INDEX ON other (value);\n")
+		b.WriteString("DROP INDEX other_idx;\n")
+		tree := mustParse(t, b.String())
+		require.Len(t, tree.Stmts, 5)
+
+		res, err := check(t, nonConcurrentIndex, tree, checkOptions{ImplicitTransaction: true})
+		require.NoError(t, err)
+		assert.Len(t, res, 2)
+	})
 }
 
 func TestConstraintKeyExcessiveLock(t *testing.T) {
@@ -101,7 +118,7 @@ func TestConstraintKeyExcessiveLock(t *testing.T) {
 		tree := mustParse(t, "ALTER TABLE pgvet ADD CONSTRAINT reference_fk FOREIGN KEY (reference) REFERENCES issues(id);")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := constraintExcessiveLock(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, constraintExcessiveLock, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 1)
 
@@ -122,7 +139,7 @@ func TestConstraintKeyExcessiveLock(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 3)
 
-		res, err := constraintExcessiveLock(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, constraintExcessiveLock, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 2)
 
@@ -136,10 +153,25 @@ func TestConstraintKeyExcessiveLock(t *testing.T) {
 		tree := mustParse(t, "ALTER TABLE pgvet ADD CONSTRAINT reference_fk FOREIGN KEY (reference) REFERENCES issues(id) NOT VALID;")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := constraintExcessiveLock(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, constraintExcessiveLock, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		assert.Empty(t, res)
 	})
+
+	t.Run("Should not flag tables created in the same file", func(t *testing.T) {
+		t.Parallel()
+
+		var b strings.Builder
+		b.WriteString("CREATE TABLE pgvet (id text PRIMARY KEY, reference text);\n")
+		b.WriteString("ALTER TABLE pgvet ADD CONSTRAINT reference_fk FOREIGN KEY (reference) REFERENCES issues(id);\n")
+		b.WriteString("ALTER TABLE other ADD CONSTRAINT reference_fk FOREIGN KEY (reference) REFERENCES issues(id);\n")
+		tree := mustParse(t, b.String())
+		require.Len(t, tree.Stmts, 3)
+
+		res, err := check(t, constraintExcessiveLock, tree, checkOptions{ImplicitTransaction: true})
+		require.NoError(t, err)
+		assert.Len(t, res, 1)
+	})
 }
 
 func TestMultipleLocks(t *testing.T) {
@@ -156,7 +188,7 @@ func TestMultipleLocks(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 4)
 
-		res, err := multipleLocks(tree, testCode, testSlug, testHelp, false)
+		res, err := check(t, multipleLocks, tree, checkOptions{})
 		require.NoError(t, err)
 		require.Len(t, res, 1)
 
@@ -179,7 +211,7 @@ func TestMultipleLocks(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 5)
 
-		res, err := multipleLocks(tree, testCode, testSlug, testHelp, false)
+		res, err := check(t, multipleLocks, tree, checkOptions{})
 		require.NoError(t, err)
 		require.Len(t, res, 2)
 
@@ -197,7 +229,7 @@ func TestMultipleLocks(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 3)
 
-		res, err := multipleLocks(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, multipleLocks, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		assert.Len(t, res, 1)
 	})
@@ -215,7 +247,24 @@ func TestMultipleLocks(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 6)
 
-		res, err := multipleLocks(tree, testCode, testSlug, testHelp, false)
+		res, err := check(t, multipleLocks, tree, checkOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, res)
+	})
+
+	t.Run("Should end the transaction on rollback", func(t *testing.T) {
+		t.Parallel()
+
+		var b strings.Builder
+		b.WriteString("BEGIN;\n")
+		b.WriteString("ALTER TABLE pgvet ADD COLUMN value text;\n")
+		b.WriteString("ROLLBACK;\n")
+		b.WriteString("ALTER TABLE othertable ADD COLUMN value text;\n")
+		b.WriteString("ALTER TABLE thirdtable ADD COLUMN value text;\n")
+		tree := mustParse(t, b.String())
+		require.Len(t, tree.Stmts, 5)
+
+		res, err := check(t, multipleLocks, tree, checkOptions{})
 		require.NoError(t, err)
 		assert.Empty(t, res)
 	})
@@ -232,11 +281,30 @@ func TestMultipleLocks(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 5)
 
-		res, err := multipleLocks(tree, testCode, testSlug, testHelp, false)
+		res, err := check(t, multipleLocks, tree, checkOptions{})
 		require.NoError(t, err)
 		assert.Empty(t, res)
 	})
 
+	t.Run("Should allow the configured number of locks", func(t *testing.T) {
+		t.Parallel()
+
+		var b strings.Builder
+		b.WriteString("BEGIN;\n")
+		b.WriteString("ALTER TABLE pgvet ADD COLUMN value text;\n")
+		b.WriteString("ALTER TABLE othertable ADD COLUMN value text;\n")
+		b.WriteString("ALTER TABLE thirdtable ADD COLUMN value text;\n")
+		b.WriteString("COMMIT;\n")
+		tree := mustParse(t, b.String())
+		require.Len(t, tree.Stmts, 5)
+
+		res, err := check(t, multipleLocks, tree, checkOptions{Options: &MultipleLocksOptions{MaxLocks: 2}})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+
+		assert.EqualValues(t, 93, res[0].StmtStart)
+	})
+
 	t.Run("Should work with END/START TRANSACTION", func(t *testing.T) {
 		t.Parallel()
 
@@ -250,7 +318,108 @@ func TestMultipleLocks(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 6)
 
-		res, err := multipleLocks(tree, testCode, testSlug, testHelp, false)
+		res, err := check(t, multipleLocks, tree, checkOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, res)
+	})
+}
+
+func TestMultipleLocksOptions(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, (&MultipleLocksOptions{MaxLocks: 1}).Validate())
+	require.Error(t, (&MultipleLocksOptions{MaxLocks: 0}).Validate())
+}
+
+func TestAddColumnWithDefault(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should find violation before PostgreSQL 11", func(t *testing.T) {
+		t.Parallel()
+
+		var b strings.Builder
+		b.WriteString("ALTER TABLE pgvet ADD COLUMN value text DEFAULT 'value';\n")
+		b.WriteString("ALTER TABLE pgvet ADD COLUMN other text;\n")
+		tree := mustParse(t, b.String())
+		require.Len(t, tree.Stmts, 2)
+
+		res, err := check(t, addColumnWithDefault, tree, checkOptions{ImplicitTransaction: true, PostgresVersion: 10})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+
+		assert.EqualValues(t, 0, res[0].StmtStart)
+		assert.Equal(t, testCode, res[0].Code)
+	})
+
+	t.Run("Should not find violation from PostgreSQL 11", func(t *testing.T) {
+		t.Parallel()
+
+		tree := mustParse(t, "ALTER TABLE pgvet ADD COLUMN value text DEFAULT 'value';")
+		require.Len(t, tree.Stmts, 1)
+
+		res, err := check(t, addColumnWithDefault, tree, checkOptions{ImplicitTransaction: true, PostgresVersion: 11})
+		require.NoError(t, err)
+		assert.Empty(t, res)
+	})
+}
+
+func TestNonConcurrentReindex(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should find violation from PostgreSQL 12", func(t *testing.T) {
+		t.Parallel()
+
+		var b strings.Builder
+		b.WriteString("REINDEX INDEX pgvet_idx;\n")
+		b.WriteString("REINDEX TABLE CONCURRENTLY pgvet;\n")
+		tree := mustParse(t, b.String())
+		require.Len(t, tree.Stmts, 2)
+
+		res, err := check(t, nonConcurrentIndex, tree, checkOptions{ImplicitTransaction: true, PostgresVersion: 12})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+
+		assert.EqualValues(t, 0, res[0].StmtStart)
+	})
+
+	t.Run("Should not find violation before PostgreSQL 12", func(t *testing.T) {
+		t.Parallel()
+
+		tree := mustParse(t, "REINDEX INDEX pgvet_idx;")
+		require.Len(t, tree.Stmts, 1)
+
+		res, err := check(t, nonConcurrentIndex, tree, checkOptions{ImplicitTransaction: true, PostgresVersion: 11})
+		require.NoError(t, err)
+		assert.Empty(t, res)
+	})
+}
+
+func TestNonConcurrentDetachPartition(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should find violation from PostgreSQL 14", func(t *testing.T) {
+		t.Parallel()
+
+		var b strings.Builder
+		b.WriteString("ALTER TABLE pgvet DETACH PARTITION pgvet_2024;\n")
+		b.WriteString("ALTER TABLE pgvet DETACH PARTITION pgvet_2025 CONCURRENTLY;\n")
+		tree := mustParse(t, b.String())
+		require.Len(t, tree.Stmts, 2)
+
+		res, err := check(t, nonConcurrentDetachPartition, tree, checkOptions{ImplicitTransaction: true, PostgresVersion: 14})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+
+		assert.EqualValues(t, 0, res[0].StmtStart)
+	})
+
+	t.Run("Should not find violation before PostgreSQL 14", func(t *testing.T) {
+		t.Parallel()
+
+		tree := mustParse(t, "ALTER TABLE pgvet DETACH PARTITION pgvet_2024;")
+		require.Len(t, tree.Stmts, 1)
+
+		res, err := check(t, nonConcurrentDetachPartition, tree, checkOptions{ImplicitTransaction: true, PostgresVersion: 13})
 		require.NoError(t, err)
 		assert.Empty(t, res)
 	})
@@ -1,8 +1,10 @@
 package rules
 
 import (
+	"fmt"
 	"maps"
 	"slices"
+	"strings"
 
 	pgquery "github.com/pganalyze/pg_query_go/v6"
 )
@@ -14,6 +16,8 @@ var miscellaneousRules = []Rule{
 		Help:     "Add an index for the foreign key constraint column",
 		Fn:       missingForeignKeyIndex,
 		Category: miscellaneous,
+		Severity: SeverityWarning,
+		Options:  &MissingForeignKeyIndexOptions{},
 	},
 	{
 		Code:     "concurrent-in-tx",
@@ -21,149 +25,232 @@ var miscellaneousRules = []Rule{
 		Help:     "Perform the operation outside of a transaction",
 		Fn:       concurrentInTX,
 		Category: miscellaneous,
+		Severity: SeverityError,
+	},
+	{
+		Code:     "add-enum-value-in-tx",
+		Slug:     "Before PostgreSQL 12 a value cannot be added to an enum inside of a transaction",
+		Help:     "Perform the operation outside of a transaction",
+		Fn:       addEnumValueInTX,
+		Category: miscellaneous,
+		Severity: SeverityError,
+	},
+	{
+		Code:     "unsupported-feature",
+		Slug:     "The statement uses a feature that the targeted PostgreSQL version does not support",
+		Help:     "Rewrite the statement without the feature, or update `postgresVersion` if the server is newer",
+		Fn:       unsupportedFeature,
+		Category: miscellaneous,
+		Severity: SeverityError,
+	},
+	{
+		Code: ParseErrorCode,
+		Slug: "The statement is not valid SQL and can't be checked by the other rules",
+		Help: "Fix the syntax of the statement",
+		// Reported by the linter for the statements that fail to parse, there is nothing to check in the parse tree
+		Fn:       func(*RuleContext) error { return nil },
+		Category: miscellaneous,
+		Severity: SeverityError,
+	},
+	{
+		Code: MissingDownMigrationCode,
+		Slug: "The migration can't be reverted because it has no down migration",
+		Help: "Add a down migration that reverts the changes",
+		// Reported by the linter for the migration tools that pair up and down migrations
+		Fn:       func(*RuleContext) error { return nil },
+		Category: miscellaneous,
+		Severity: SeverityWarning,
+	},
+	{
+		Code: DuplicateMigrationVersionCode,
+		Slug: "Several migrations have the same version, the migration tool refuses to run them",
+		Help: "Give the migration a version of its own",
+		// Reported by the linter for the migration tools that version the migrations
+		Fn:       func(*RuleContext) error { return nil },
+		Category: miscellaneous,
+		Severity: SeverityError,
 	},
 }
 
-func missingForeignKeyIndex(
-	tree *pgquery.ParseResult,
-	code Code,
-	slug,
-	help string,
-	implicitTransaction bool,
-) ([]Result, error) {
+const (
+	// ParseErrorCode is the code of the rule violated by statements that can't be parsed.
+	ParseErrorCode Code = "parse-error"
+	// MissingDownMigrationCode is the code of the rule violated by migrations without a down migration.
+	MissingDownMigrationCode Code = "missing-down-migration"
+	// DuplicateMigrationVersionCode is the code of the rule violated by migrations sharing a version.
+	DuplicateMigrationVersionCode Code = "duplicate-migration-version"
+)
+
+// MissingForeignKeyIndexOptions are the options of the missing-foreign-key-index rule.
+type MissingForeignKeyIndexOptions struct {
+	// Tables that are exempt from the rule.
+	IgnoreTables []string `yaml:"ignoreTables"`
+}
+
+func (o *MissingForeignKeyIndexOptions) Validate() error {
+	return nil
+}
+
+func missingForeignKeyIndex(ctx *RuleContext) error {
+	opts := optionsOf[*MissingForeignKeyIndexOptions](ctx)
+
 	type stmtMarker struct {
-		stmtStart int32
-		stmtEnd   int32
+		cursor   *Cursor
+		span     Span
+		relation *pgquery.RangeVar
+		column   string
 	}
 
 	unindexedConstraints := map[string]stmtMarker{}
+	addConstraint := func(c *Cursor, span Span, relation *pgquery.RangeVar, columnName string) {
+		tableName := relation.GetRelname()
+		if slices.Contains(opts.IgnoreTables, tableName) {
+			return
+		}
+		unindexedConstraints[tableName+"."+columnName] = stmtMarker{
+			// The cursor moves on, keep the statement
+			cursor:   &Cursor{Stmt: c.Stmt},
+			span:     span,
+			relation: relation,
+			column:   columnName,
+		}
+	}
 
-	for _, stmt := range tree.Stmts {
-		// Create table statement, check for FK constraints
-		createStmt := stmt.GetStmt().GetCreateStmt()
-		for _, col := range createStmt.GetTableElts() {
+	// Create table statement, check for FK constraints
+	On(ctx, func(c *Cursor, stmt *pgquery.CreateStmt) {
+		for _, col := range stmt.GetTableElts() {
 			for _, constraint := range col.GetColumnDef().GetConstraints() {
 				if constraint.GetConstraint().GetContype() == pgquery.ConstrType_CONSTR_FOREIGN {
-					tableName := createStmt.GetRelation().GetRelname()
-					columnName := col.GetColumnDef().GetColname()
-					entry := tableName + "." + columnName
-					unindexedConstraints[entry] = stmtMarker{
-						stmtStart: stmt.GetStmtLocation(),
-						stmtEnd:   stmt.GetStmtLocation() + stmt.GetStmtLen(),
-					}
+					span := ctx.clauseSpan(c, col.GetColumnDef().GetLocation())
+					addConstraint(c, span, stmt.GetRelation(), col.GetColumnDef().GetColname())
 				}
 			}
 		}
+	})
 
-		// Alter table add constraint statement, check for FK constraints
-		alterTableStmt := stmt.GetStmt().GetAlterTableStmt()
-		for _, cmd := range alterTableStmt.GetCmds() {
+	// Alter table add constraint statement, check for FK constraints
+	On(ctx, func(c *Cursor, stmt *pgquery.AlterTableStmt) {
+		for _, cmd := range stmt.GetCmds() {
 			alterTableCmd := cmd.GetAlterTableCmd()
 
 			isAddConstraint := alterTableCmd.GetSubtype() == pgquery.AlterTableType_AT_AddConstraint
 			constraint := alterTableCmd.GetDef().GetConstraint()
 			isForeignKey := constraint.GetContype() == pgquery.ConstrType_CONSTR_FOREIGN
 			if isAddConstraint && isForeignKey {
-				tableName := alterTableStmt.GetRelation().GetRelname()
 				columnName := constraint.GetFkAttrs()[0].GetString_().GetSval()
-				entry := tableName + "." + columnName
-				unindexedConstraints[entry] = stmtMarker{
-					stmtStart: stmt.GetStmtLocation(),
-					stmtEnd:   stmt.GetStmtLocation() + stmt.GetStmtLen(),
-				}
+				addConstraint(c, ctx.cmdSpan(c, alterTableCmd), stmt.GetRelation(), columnName)
 			}
 		}
+	})
 
-		// Create index statements, pop if index is found for FK
-		indexStmt := stmt.GetStmt().GetIndexStmt()
-		tableName := indexStmt.GetRelation().GetRelname()
-		for _, param := range indexStmt.GetIndexParams() {
-			entry := tableName + "." + param.GetIndexElem().GetName()
-			delete(unindexedConstraints, entry)
+	// Create index statements, pop if index is found for FK
+	On(ctx, func(_ *Cursor, stmt *pgquery.IndexStmt) {
+		tableName := stmt.GetRelation().GetRelname()
+		for _, param := range stmt.GetIndexParams() {
+			delete(unindexedConstraints, tableName+"."+param.GetIndexElem().GetName())
 		}
-	}
+	})
 
-	var results []Result
-	sortedConstraints := slices.SortedFunc(maps.Values(unindexedConstraints), func(a, b stmtMarker) int {
-		if a.stmtStart < b.stmtStart {
-			return -1
+	OnEnd(ctx, func() {
+		sortedConstraints := slices.SortedFunc(maps.Values(unindexedConstraints), func(a, b stmtMarker) int {
+			if a.span.Start < b.span.Start {
+				return -1
+			}
+			return 1
+		})
+
+		for _, marker := range sortedConstraints {
+			// The index may be created by a later migration
+			if ctx.FinalCatalog != nil && ctx.FinalCatalog.IsIndexed(marker.relation, marker.column) {
+				continue
+			}
+			objects := tableObjects(marker.relation)
+			objects.Column = marker.column
+			ctx.Report(marker.cursor, marker.span, objects, "Foreign key column "+objects.qualifiedColumn()+" has no index")
 		}
-		return 1
 	})
+	return nil
+}
 
-	for _, marker := range sortedConstraints {
-		r := Result{
-			Slug:      slug,
-			Help:      help,
-			Code:      code,
-			StmtStart: marker.stmtStart,
-			StmtEnd:   marker.stmtEnd,
+func concurrentInTX(ctx *RuleContext) error {
+	// Check for index creation
+	On(ctx, func(c *Cursor, stmt *pgquery.IndexStmt) {
+		if ctx.inTransaction() && stmt.GetConcurrent() {
+			objects := tableObjects(stmt.GetRelation())
+			objects.Index = stmt.GetIdxname()
+			ctx.Report(c, ctx.stmtSpan(c), objects, indexName(objects)+" is created concurrently inside a transaction")
 		}
-		results = append(results, r)
-	}
+	})
 
-	return results, nil
-}
+	// Check for index drop
+	On(ctx, func(c *Cursor, stmt *pgquery.DropStmt) {
+		isDropIndex := stmt.GetRemoveType() == pgquery.ObjectType_OBJECT_INDEX
+		if ctx.inTransaction() && isDropIndex && stmt.GetConcurrent() {
+			objects, names := droppedObjects(stmt)
+			ctx.Report(c, ctx.stmtSpan(c), objects, "Index "+strings.Join(names, ", ")+" is dropped concurrently inside a transaction")
+		}
+	})
 
-func concurrentInTX(
-	tree *pgquery.ParseResult,
-	code Code,
-	slug,
-	help string,
-	implicitTransaction bool,
-) ([]Result, error) {
-	var results []Result
-	tracker := newTXTracker(implicitTransaction)
-
-	for _, stmt := range tree.Stmts {
-		// Check for new tx or commit
-		if txStmt := stmt.GetStmt().GetTransactionStmt(); txStmt != nil {
-			switch txStmt.GetKind() {
-			case pgquery.TransactionStmtKind_TRANS_STMT_COMMIT:
-				tracker.commitTx()
-			case pgquery.TransactionStmtKind_TRANS_STMT_BEGIN, pgquery.TransactionStmtKind_TRANS_STMT_START:
-				tracker.beginTx()
-			}
+	// Check for reindexing
+	On(ctx, func(c *Cursor, stmt *pgquery.ReindexStmt) {
+		if ctx.inTransaction() && isConcurrentReindex(stmt) {
+			objects, target := reindexTarget(stmt)
+			ctx.Report(c, ctx.stmtSpan(c), objects, "Reindexing "+target+" concurrently inside a transaction")
 		}
+	})
 
-		if !tracker.inTx {
-			continue
+	// Check for partition detach
+	On(ctx, func(c *Cursor, cmd *pgquery.PartitionCmd) {
+		if ctx.inTransaction() && cmd.GetConcurrent() {
+			objects := alteredTable(c)
+			message := fmt.Sprintf("Partition %s is detached concurrently inside a transaction", tableObjects(cmd.GetName()).qualifiedTable())
+			ctx.Report(c, partitionCmdSpan(ctx, c), objects, message)
 		}
+	})
+	return nil
+}
 
-		// Check for index creation
-		if indexStmt := stmt.GetStmt().GetIndexStmt(); indexStmt != nil {
-			if !indexStmt.Concurrent {
-				continue
-			}
+func addEnumValueInTX(ctx *RuleContext) error {
+	if ctx.versionAtLeast(12) {
+		return nil
+	}
 
-			r := Result{
-				Slug:      slug,
-				Help:      help,
-				Code:      code,
-				StmtStart: stmt.GetStmtLocation(),
-				StmtEnd:   stmt.GetStmtLocation() + stmt.GetStmtLen(),
-			}
-			results = append(results, r)
+	On(ctx, func(c *Cursor, stmt *pgquery.AlterEnumStmt) {
+		// OldVal is set when a value is renamed
+		if ctx.inTransaction() && stmt.GetNewVal() != "" && stmt.GetOldVal() == "" {
+			schema, name := splitName(stmt.GetTypeName())
+			message := fmt.Sprintf("Value '%s' is added to enum %s.%s inside a transaction", stmt.GetNewVal(), schema, name)
+			ctx.Report(c, ctx.stmtSpan(c), Objects{Schema: schema}, message)
 		}
+	})
+	return nil
+}
 
-		// Check for index drop
-		if dropStmt := stmt.GetStmt().GetDropStmt(); dropStmt != nil {
-			if !dropStmt.Concurrent {
-				continue
-			}
-			if dropStmt.GetRemoveType() == pgquery.ObjectType_OBJECT_INDEX {
-				r := Result{
-					Slug:      slug,
-					Help:      help,
-					Code:      code,
-					StmtStart: stmt.GetStmtLocation(),
-					StmtEnd:   stmt.GetStmtLocation() + stmt.GetStmtLen(),
-				}
-				results = append(results, r)
-			}
+func unsupportedFeature(ctx *RuleContext) error {
+	On(ctx, func(c *Cursor, stmt *pgquery.ReindexStmt) {
+		if !ctx.versionAtLeast(12) && isConcurrentReindex(stmt) {
+			objects, target := reindexTarget(stmt)
+			message := fmt.Sprintf("Reindexing %s concurrently is not supported by PostgreSQL %d", target, ctx.postgresVersion())
+			ctx.ReportWithHelp(c, ctx.stmtSpan(c), objects, message, "`REINDEX CONCURRENTLY` requires PostgreSQL 12, remove the `CONCURRENTLY` option")
 		}
+	})
 
-	}
+	On(ctx, func(c *Cursor, cmd *pgquery.PartitionCmd) {
+		if !ctx.versionAtLeast(14) && cmd.GetConcurrent() {
+			message := fmt.Sprintf(
+				"Detaching partition %s concurrently is not supported by PostgreSQL %d",
+				tableObjects(cmd.GetName()).qualifiedTable(), ctx.postgresVersion(),
+			)
+			ctx.ReportWithHelp(c, partitionCmdSpan(ctx, c), alteredTable(c), message, "`DETACH PARTITION CONCURRENTLY` requires PostgreSQL 14, remove the `CONCURRENTLY` option")
+		}
+	})
+	return nil
+}
 
-	return results, nil
+// partitionCmdSpan returns the span of the ATTACH/DETACH PARTITION sub-command of the cursor.
+func partitionCmdSpan(ctx *RuleContext, c *Cursor) Span {
+	if cmd, ok := ancestorOf[*pgquery.AlterTableCmd](c); ok {
+		return ctx.cmdSpan(c, cmd)
+	}
+	return ctx.stmtSpan(c)
 }
@@ -17,7 +17,7 @@ func TestMissingForeignKeyIndex(t *testing.T) {
 		tree := mustParse(t, "CREATE TABLE pgvet (reference text REFERENCES parent(id));")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := missingForeignKeyIndex(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, missingForeignKeyIndex, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 1)
 
@@ -34,7 +34,7 @@ func TestMissingForeignKeyIndex(t *testing.T) {
 		tree := mustParse(t, "ALTER TABLE pgvet ADD CONSTRAINT reference_fk FOREIGN KEY (reference) REFERENCES parent(id);")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := missingForeignKeyIndex(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, missingForeignKeyIndex, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 1)
 	})
@@ -49,7 +49,7 @@ func TestMissingForeignKeyIndex(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 3)
 
-		res, err := missingForeignKeyIndex(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, missingForeignKeyIndex, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 2)
 
@@ -57,6 +57,23 @@ func TestMissingForeignKeyIndex(t *testing.T) {
 		assert.EqualValues(t, 110, res[1].StmtStart)
 	})
 
+	t.Run("Should not find references on ignored tables", func(t *testing.T) {
+		t.Parallel()
+
+		var b strings.Builder
+		b.WriteString("CREATE TABLE pgvet (reference text REFERENCES parent(id));\n")
+		b.WriteString("ALTER TABLE other ADD CONSTRAINT reference_fk FOREIGN KEY (reference) REFERENCES parent(id);")
+		tree := mustParse(t, b.String())
+		require.Len(t, tree.Stmts, 2)
+
+		opts := &MissingForeignKeyIndexOptions{IgnoreTables: []string{"pgvet"}}
+		res, err := check(t, missingForeignKeyIndex, tree, checkOptions{ImplicitTransaction: true, Options: opts})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+
+		assert.EqualValues(t, 58, res[0].StmtStart)
+	})
+
 	t.Run("Should not find references that have indexes", func(t *testing.T) {
 		t.Parallel()
 
@@ -66,10 +83,27 @@ func TestMissingForeignKeyIndex(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 2)
 
-		res, err := missingForeignKeyIndex(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, missingForeignKeyIndex, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		assert.Empty(t, res)
 	})
+
+	t.Run("Should not find references indexed in a later migration", func(t *testing.T) {
+		t.Parallel()
+
+		tree := mustParse(t, "CREATE TABLE pgvet (reference text REFERENCES parent(id), other text REFERENCES parent(id));")
+		final := NewCatalog()
+		final.ApplyTree(tree, "1.sql")
+		final.ApplyTree(mustParse(t, "CREATE INDEX ON pgvet(reference);"), "2.sql")
+
+		ctx := &RuleContext{
+			Rule:         Rule{Code: testCode, Fn: missingForeignKeyIndex, Options: &MissingForeignKeyIndexOptions{}},
+			File:         "1.sql",
+			FinalCatalog: final,
+		}
+		require.NoError(t, Check(tree, nil, []*RuleContext{ctx}))
+		assert.Len(t, ctx.Results(), 1)
+	})
 }
 
 func TestConcurrentInTX(t *testing.T) {
@@ -81,7 +115,7 @@ func TestConcurrentInTX(t *testing.T) {
 		tree := mustParse(t, "CREATE INDEX CONCURRENTLY pgvet_idx ON pgvet(value);\n")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := concurrentInTX(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, concurrentInTX, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 1)
 
@@ -98,7 +132,7 @@ func TestConcurrentInTX(t *testing.T) {
 		tree := mustParse(t, "DROP INDEX CONCURRENTLY pgvet_idx;\n")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := concurrentInTX(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, concurrentInTX, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 1)
 
@@ -118,7 +152,7 @@ func TestConcurrentInTX(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 2)
 
-		res, err := concurrentInTX(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, concurrentInTX, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 2)
 
@@ -132,7 +166,7 @@ func TestConcurrentInTX(t *testing.T) {
 		tree := mustParse(t, "CREATE INDEX pgvet_idx ON pgvet(value);\n")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := concurrentInTX(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, concurrentInTX, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		assert.Empty(t, res)
 	})
@@ -145,8 +179,95 @@ func TestConcurrentInTX(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := concurrentInTX(tree, testCode, testSlug, testHelp, false)
+		res, err := check(t, concurrentInTX, tree, checkOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, res)
+	})
+
+	t.Run("Should find violations from REINDEX and DETACH PARTITION", func(t *testing.T) {
+		t.Parallel()
+
+		var b strings.Builder
+		b.WriteString("REINDEX INDEX CONCURRENTLY pgvet_idx;\n")
+		b.WriteString("ALTER TABLE pgvet DETACH PARTITION pgvet_2025 CONCURRENTLY;\n")
+		tree := mustParse(t, b.String())
+		require.Len(t, tree.Stmts, 2)
+
+		res, err := check(t, concurrentInTX, tree, checkOptions{ImplicitTransaction: true})
+		require.NoError(t, err)
+		assert.Len(t, res, 2)
+	})
+}
+
+func TestAddEnumValueInTX(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should find violation before PostgreSQL 12", func(t *testing.T) {
+		t.Parallel()
+
+		var b strings.Builder
+		b.WriteString("ALTER TYPE mood ADD VALUE 'happy';\n")
+		b.WriteString("ALTER TYPE mood RENAME VALUE 'sad' TO 'unhappy';\n")
+		tree := mustParse(t, b.String())
+		require.Len(t, tree.Stmts, 2)
+
+		res, err := check(t, addEnumValueInTX, tree, checkOptions{ImplicitTransaction: true, PostgresVersion: 11})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+
+		assert.EqualValues(t, 0, res[0].StmtStart)
+	})
+
+	t.Run("Should not find violation outside of transactions", func(t *testing.T) {
+		t.Parallel()
+
+		tree := mustParse(t, "ALTER TYPE mood ADD VALUE 'happy';")
+		require.Len(t, tree.Stmts, 1)
+
+		res, err := check(t, addEnumValueInTX, tree, checkOptions{PostgresVersion: 11})
 		require.NoError(t, err)
 		assert.Empty(t, res)
 	})
+
+	t.Run("Should not find violation from PostgreSQL 12", func(t *testing.T) {
+		t.Parallel()
+
+		tree := mustParse(t, "ALTER TYPE mood ADD VALUE 'happy';")
+		require.Len(t, tree.Stmts, 1)
+
+		res, err := check(t, addEnumValueInTX, tree, checkOptions{ImplicitTransaction: true, PostgresVersion: 12})
+		require.NoError(t, err)
+		assert.Empty(t, res)
+	})
+}
+
+func TestUnsupportedFeature(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+	b.WriteString("REINDEX INDEX CONCURRENTLY pgvet_idx;\n")
+	b.WriteString("ALTER TABLE pgvet DETACH PARTITION pgvet_2025 CONCURRENTLY;\n")
+	tree := mustParse(t, b.String())
+	require.Len(t, tree.Stmts, 2)
+
+	tests := map[string]struct {
+		version int
+		want    int
+	}{
+		"PostgreSQL 11": {11, 2},
+		"PostgreSQL 13": {13, 1},
+		"PostgreSQL 14": {14, 0},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := check(t, unsupportedFeature, tree, checkOptions{PostgresVersion: tc.version})
+			require.NoError(t, err)
+			require.Len(t, res, tc.want)
+			for _, r := range res {
+				assert.NotEqual(t, testHelp, r.Help)
+			}
+		})
+	}
 }
@@ -1,6 +1,8 @@
 package rules
 
 import (
+	"fmt"
+
 	pgquery "github.com/pganalyze/pg_query_go/v6"
 )
 
@@ -11,6 +13,7 @@ var nullabilityRules = []Rule{
 		Help:     "Make the column nullable or add a default",
 		Fn:       addNonNullColumn,
 		Category: nullability,
+		Severity: SeverityError,
 	},
 	{
 		Code:     "set-non-null-column",
@@ -18,77 +21,55 @@ var nullabilityRules = []Rule{
 		Help:     "Ensure that the column does not contain any null values",
 		Fn:       alterColumnNotNullable,
 		Category: nullability,
+		Severity: SeverityError,
 	},
 }
 
-func addNonNullColumn(
-	tree *pgquery.ParseResult,
-	code Code,
-	slug,
-	help string,
-	implicitTransaction bool,
-) ([]Result, error) {
-	var results []Result
-	for _, decl := range FilterStatements[*pgquery.Node_AlterTableStmt](tree.Stmts) {
-		for _, cmd := range decl.Stmt.AlterTableStmt.GetCmds() {
-			subtype := cmd.GetAlterTableCmd().GetSubtype()
-			if subtype != pgquery.AlterTableType_AT_AddColumn {
-				continue
-			}
-
-			column := cmd.GetAlterTableCmd().GetDef().GetColumnDef()
-			if column == nil {
-				continue
-			}
+func addNonNullColumn(ctx *RuleContext) error {
+	On(ctx, func(c *Cursor, cmd *pgquery.AlterTableCmd) {
+		if cmd.GetSubtype() != pgquery.AlterTableType_AT_AddColumn {
+			return
+		}
 
-			var hasDefault bool
-			var r *Result
+		column := cmd.GetDef().GetColumnDef()
+		if column == nil {
+			return
+		}
 
-			for _, constraint := range column.GetConstraints() {
-				if constraint.GetConstraint().GetContype() == pgquery.ConstrType_CONSTR_DEFAULT {
-					hasDefault = true
-				}
+		var hasDefault bool
+		var notNull *pgquery.Constraint
+		for _, constraint := range column.GetConstraints() {
+			if constraint.GetConstraint().GetContype() == pgquery.ConstrType_CONSTR_DEFAULT {
+				hasDefault = true
+			}
 
-				if constraint.GetConstraint().GetContype() == pgquery.ConstrType_CONSTR_NOTNULL {
-					r = &Result{
-						Slug:      slug,
-						Help:      help,
-						Code:      code,
-						StmtStart: decl.Start,
-						StmtEnd:   decl.End,
-					}
-				}
+			if constraint.GetConstraint().GetContype() == pgquery.ConstrType_CONSTR_NOTNULL {
+				notNull = constraint.GetConstraint()
 			}
-			if !hasDefault && r != nil {
-				results = append(results, *r)
+		}
+		if !hasDefault && notNull != nil && !isAlteringNewTable(ctx, c) {
+			span := ctx.constraintSpan(c, column, notNull)
+			objects := alteredTable(c)
+			objects.Column = column.GetColname()
+			message := fmt.Sprintf("Adding non-nullable column %s without a default", objects.qualifiedColumn())
+			if ctx.versionAtLeast(11) {
+				ctx.Report(c, span, objects, message)
+				return
 			}
+			// Adding a default rewrites the table before PostgreSQL 11
+			ctx.ReportWithHelp(c, span, objects, message, "Add the column as nullable, backfill it and then make it non-nullable")
 		}
-	}
-	return results, nil
+	})
+	return nil
 }
 
-func alterColumnNotNullable(
-	tree *pgquery.ParseResult,
-	code Code,
-	slug,
-	help string,
-	implicitTransaction bool,
-) ([]Result, error) {
-	var results []Result
-	for _, stmt := range tree.Stmts {
-		for _, cmd := range stmt.Stmt.GetAlterTableStmt().GetCmds() {
-			subtype := cmd.GetAlterTableCmd().GetSubtype()
-			if subtype == pgquery.AlterTableType_AT_SetNotNull {
-				r := Result{
-					Slug:      slug,
-					Help:      help,
-					Code:      code,
-					StmtStart: stmt.GetStmtLocation(),
-					StmtEnd:   stmt.GetStmtLocation() + stmt.GetStmtLen(),
-				}
-				results = append(results, r)
-			}
+func alterColumnNotNullable(ctx *RuleContext) error {
+	On(ctx, func(c *Cursor, cmd *pgquery.AlterTableCmd) {
+		if cmd.GetSubtype() == pgquery.AlterTableType_AT_SetNotNull && !isAlteringNewTable(ctx, c) {
+			objects := alteredTable(c)
+			objects.Column = cmd.GetName()
+			ctx.Report(c, ctx.cmdSpan(c, cmd), objects, fmt.Sprintf("Making column %s non-nullable", objects.qualifiedColumn()))
 		}
-	}
-	return results, nil
+	})
+	return nil
 }
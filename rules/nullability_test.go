@@ -17,7 +17,7 @@ func TestAddNonNullColumn(t *testing.T) {
 		tree := mustParse(t, "ALTER TABLE pgvet ADD COLUMN value text NOT NULL;")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := addNonNullColumn(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, addNonNullColumn, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 1)
 
@@ -38,7 +38,7 @@ func TestAddNonNullColumn(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 3)
 
-		res, err := addNonNullColumn(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, addNonNullColumn, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 2)
 
@@ -52,7 +52,7 @@ func TestAddNonNullColumn(t *testing.T) {
 		tree := mustParse(t, "ALTER TABLE pgvet ADD COLUMN value text NOT NULL DEFAULT '1';")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := addNonNullColumn(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, addNonNullColumn, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		assert.Empty(t, res)
 	})
@@ -63,7 +63,7 @@ func TestAddNonNullColumn(t *testing.T) {
 		tree := mustParse(t, "ALTER TABLE pgvet ADD COLUMN value text;")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := addNonNullColumn(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, addNonNullColumn, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		assert.Empty(t, res)
 	})
@@ -79,10 +79,75 @@ func TestAddNonNullColumn(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 4)
 
-		res, err := addNonNullColumn(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, addNonNullColumn, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		assert.Empty(t, res)
 	})
+
+	t.Run("Should not flag tables created in the same file", func(t *testing.T) {
+		t.Parallel()
+
+		var b strings.Builder
+		b.WriteString("CREATE TABLE pgvet (id text PRIMARY KEY);\n")
+		b.WriteString("ALTER TABLE pgvet ADD COLUMN value text NOT NULL;\n")
+		b.WriteString("ALTER TABLE other ADD COLUMN value text NOT NULL;\n")
+		tree := mustParse(t, b.String())
+		require.Len(t, tree.Stmts, 3)
+
+		res, err := check(t, addNonNullColumn, tree, checkOptions{ImplicitTransaction: true})
+		require.NoError(t, err)
+		assert.Len(t, res, 1)
+	})
+
+	t.Run("Should flag new tables once rows are added", func(t *testing.T) {
+		t.Parallel()
+
+		cases := map[string]string{
+			"insert": "CREATE TABLE pgvet (id int);\nINSERT INTO pgvet SELECT id FROM users;\n",
+			"copy":   "CREATE TABLE pgvet (id int);\nCOPY pgvet FROM STDIN;\n",
+		}
+		for name, sql := range cases {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				tree := mustParse(t, sql+"ALTER TABLE pgvet ADD COLUMN value int NOT NULL;\n")
+				res, err := check(t, addNonNullColumn, tree, checkOptions{ImplicitTransaction: true})
+				require.NoError(t, err)
+				assert.Len(t, res, 1)
+			})
+		}
+	})
+
+	t.Run("Should skip new tables until the file ends", func(t *testing.T) {
+		t.Parallel()
+
+		cases := map[string]string{
+			"no transaction": "CREATE TABLE pgvet (id int);\nALTER TABLE pgvet ADD COLUMN value int NOT NULL;\n",
+			"committed":      "BEGIN;\nCREATE TABLE pgvet (id int);\nCOMMIT;\nALTER TABLE pgvet ADD COLUMN value int NOT NULL;\n",
+		}
+		for name, sql := range cases {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				tree := mustParse(t, sql)
+				res, err := check(t, addNonNullColumn, tree, checkOptions{ImplicitTransaction: false})
+				require.NoError(t, err)
+				assert.Empty(t, res)
+			})
+		}
+	})
+
+	t.Run("Should not suggest a default before PostgreSQL 11", func(t *testing.T) {
+		t.Parallel()
+
+		tree := mustParse(t, "ALTER TABLE pgvet ADD COLUMN value text NOT NULL;")
+		require.Len(t, tree.Stmts, 1)
+
+		res, err := check(t, addNonNullColumn, tree, checkOptions{ImplicitTransaction: true, PostgresVersion: 10})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		assert.NotEqual(t, testHelp, res[0].Help)
+	})
 }
 
 func TestAlterColumnNotNullable(t *testing.T) {
@@ -94,7 +159,7 @@ func TestAlterColumnNotNullable(t *testing.T) {
 		tree := mustParse(t, "ALTER TABLE pgvet ALTER COLUMN value SET NOT NULL;")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := alterColumnNotNullable(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, alterColumnNotNullable, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 1)
 
@@ -115,7 +180,7 @@ func TestAlterColumnNotNullable(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 3)
 
-		res, err := alterColumnNotNullable(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, alterColumnNotNullable, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 2)
 
@@ -129,7 +194,7 @@ func TestAlterColumnNotNullable(t *testing.T) {
 		tree := mustParse(t, "ALTER TABLE pgvet ALTER COLUMN value DROP NOT NULL;")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := alterColumnNotNullable(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, alterColumnNotNullable, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		assert.Empty(t, res)
 	})
@@ -144,8 +209,23 @@ func TestAlterColumnNotNullable(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 3)
 
-		res, err := alterColumnNotNullable(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, alterColumnNotNullable, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		assert.Empty(t, res)
 	})
+
+	t.Run("Should not flag tables created in the same file", func(t *testing.T) {
+		t.Parallel()
+
+		var b strings.Builder
+		b.WriteString("CREATE TABLE pgvet (id text PRIMARY KEY, value text);\n")
+		b.WriteString("ALTER TABLE pgvet ALTER COLUMN value SET NOT NULL;\n")
+		b.WriteString("ALTER TABLE other ALTER COLUMN value SET NOT NULL;\n")
+		tree := mustParse(t, b.String())
+		require.Len(t, tree.Stmts, 3)
+
+		res, err := check(t, alterColumnNotNullable, tree, checkOptions{ImplicitTransaction: true})
+		require.NoError(t, err)
+		assert.Len(t, res, 1)
+	})
 }
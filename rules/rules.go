@@ -1,6 +1,8 @@
 package rules
 
 import (
+	"reflect"
+
 	pgquery "github.com/pganalyze/pg_query_go/v6"
 )
 
@@ -13,13 +15,51 @@ const (
 	types         = "types"
 )
 
+// DefaultPostgresVersion is the major version of PostgreSQL the rules target unless configured otherwise.
+const DefaultPostgresVersion = 17
+
+// MinPostgresVersion is the oldest major version of PostgreSQL that can be targeted.
+const MinPostgresVersion = 9
+
 type Rule struct {
-	Code              Code
-	Slug              string
-	Help              string
-	Fn                func(*pgquery.ParseResult, Code, string, string, bool) ([]Result, error)
+	Code Code
+	Slug string
+	Help string
+	// Fn registers the node handlers of the rule, see On. The handlers are called while the tree is walked.
+	Fn func(*RuleContext) error
+	// Fix returns the edits that fix the violations of the rule in a statement, nil if the rule can't be fixed
+	// automatically.
+	Fix               func(*FixContext) []Edit
 	Category          string
+	Severity          Severity
 	DisabledByDefault bool
+	// Default options for rules that can be configured, nil if the rule has none.
+	Options Options
+}
+
+// Options are rule specific settings, set under `options` for the rule in the config file.
+type Options interface {
+	Validate() error
+}
+
+// NewOptions returns a copy of the rule's default options, or nil if the rule has no options.
+func (r Rule) NewOptions() Options {
+	if r.Options == nil {
+		return nil
+	}
+	opts := reflect.New(reflect.TypeOf(r.Options).Elem())
+	opts.Elem().Set(reflect.ValueOf(r.Options).Elem())
+	return opts.Interface().(Options)
+}
+
+// Breaking reports whether the rule is about changes that break the clients of the schema.
+func (r Rule) Breaking() bool {
+	return r.Category == breaking
+}
+
+// Idempotency reports whether the rule is about statements that fail when the migration runs again.
+func (r Rule) Idempotency() bool {
+	return r.Category == idempotency
 }
 
 func AllRules() []Rule {
@@ -33,12 +73,210 @@ func AllRules() []Rule {
 	return rules
 }
 
+// Lookup returns the rule with the given code.
+func Lookup(code Code) (Rule, bool) {
+	for _, rule := range AllRules() {
+		if rule.Code == code {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
 type Code string
 
+// Severity of a rule violation, decides if the linter should fail on it.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+var severityLevels = map[Severity]int{
+	SeverityInfo:    1,
+	SeverityWarning: 2,
+	SeverityError:   3,
+}
+
+func (s Severity) Valid() bool {
+	_, ok := severityLevels[s]
+	return ok
+}
+
+// AtLeast reports whether s is at least as severe as other.
+func (s Severity) AtLeast(other Severity) bool {
+	return severityLevels[s] >= severityLevels[other]
+}
+
 type Result struct {
-	Slug      string
-	Help      string
-	Code      Code
+	Slug string
+	Help string
+	Code Code
+	// Describes the violation in the context of the statement, e.g. "Dropping column public.pgvet.id".
+	Message   string
+	Objects   Objects
 	StmtStart int32
 	StmtEnd   int32
+	// The part of the statement the violation is about, e.g. a sub-command or a constraint.
+	Start int32
+	End   int32
+	// The position of the ALTER sub-command the violation is about, starting at 1. Zero if the violation is about the
+	// statement as a whole.
+	Subcommand int
+}
+
+// Objects names the database objects a violation is about, fields that don't apply are empty.
+type Objects struct {
+	Schema     string
+	Table      string
+	Column     string
+	Index      string
+	Constraint string
+}
+
+// tableObjects returns the objects for the relation, with the schema defaulting to public.
+func tableObjects(relation *pgquery.RangeVar) Objects {
+	return Objects{Schema: schemaOr(relation.GetSchemaname(), ""), Table: relation.GetRelname()}
+}
+
+// qualifiedTable returns the qualified name of the table, e.g. public.pgvet.
+func (o Objects) qualifiedTable() string {
+	return o.Schema + "." + o.Table
+}
+
+// qualifiedColumn returns the qualified name of the column, e.g. public.pgvet.id.
+func (o Objects) qualifiedColumn() string {
+	return o.qualifiedTable() + "." + o.Column
+}
+
+// RuleContext is passed to a rule when it is run against a file.
+type RuleContext struct {
+	Tree *pgquery.ParseResult
+	Rule Rule
+	// The options the rule is run with, the rule's defaults are used if nil.
+	Options Options
+	// The path of the file being linted.
+	File string
+	// The SQL the tree was parsed from, used to find the clauses violations are about. Violations span the whole
+	// statement if empty.
+	Source string
+	// If true the file is treated as running inside a transaction by default.
+	ImplicitTransaction bool
+	// The major version of the PostgreSQL server the migrations run on, DefaultPostgresVersion if zero.
+	PostgresVersion int
+	// The schema as it is before the statement being visited, including the changes of previous files.
+	Catalog *Catalog
+	// The schema after all the linted files have been applied, nil if unknown.
+	FinalCatalog *Catalog
+
+	walker  *walker
+	results []Result
+}
+
+// Report emits a violation of the rule for the statement of the cursor, pointing at the span of the statement.
+// The message and objects describe what the statement does to which objects.
+func (c *RuleContext) Report(cur *Cursor, span Span, objects Objects, message string) {
+	c.results = append(c.results, Result{
+		Slug:       c.Rule.Slug,
+		Help:       c.Rule.Help,
+		Code:       c.Rule.Code,
+		Message:    message,
+		Objects:    objects,
+		StmtStart:  cur.StmtStart(),
+		StmtEnd:    c.stmtEnd(cur),
+		Start:      span.Start,
+		End:        span.End,
+		Subcommand: c.subcommand(cur, span),
+	})
+}
+
+// ReportWithHelp reports a violation with help text that replaces the rule's, e.g. when the fix depends on the version.
+func (c *RuleContext) ReportWithHelp(cur *Cursor, span Span, objects Objects, message, help string) {
+	c.Report(cur, span, objects, message)
+	c.results[len(c.results)-1].Help = help
+}
+
+// Results returns the violations reported so far.
+func (c *RuleContext) Results() []Result {
+	return c.results
+}
+
+// postgresVersion returns the targeted major version of PostgreSQL.
+// inTransaction reports whether the statement being walked runs inside a transaction.
+func (c *RuleContext) inTransaction() bool {
+	return c.walker.tx.inTx
+}
+
+func (c *RuleContext) postgresVersion() int {
+	if c.PostgresVersion == 0 {
+		return DefaultPostgresVersion
+	}
+	return c.PostgresVersion
+}
+
+// versionAtLeast reports whether the targeted PostgreSQL version is the given major version or newer.
+func (c *RuleContext) versionAtLeast(version int) bool {
+	return c.postgresVersion() >= version
+}
+
+// optionsOf returns the options the rule is run with.
+func optionsOf[T Options](ctx *RuleContext) T {
+	if opts, ok := ctx.Options.(T); ok {
+		return opts
+	}
+	return ctx.Rule.Options.(T)
+}
+
+// isNewTable reports whether the table was created earlier in the file being linted and no rows have been added to it
+// since. Such a table is empty and nothing uses it before the file is deployed, so the rules that guard populated
+// tables don't apply to it.
+func isNewTable(ctx *RuleContext, relation *pgquery.RangeVar) bool {
+	table := ctx.Catalog.Table(relation)
+	return table != nil && table.New
+}
+
+// isAlteringNewTable reports whether the node belongs to an ALTER TABLE of a new table, see isNewTable.
+func isAlteringNewTable(ctx *RuleContext, c *Cursor) bool {
+	stmt, ok := ancestorOf[*pgquery.AlterTableStmt](c)
+	return ok && isNewTable(ctx, stmt.GetRelation())
+}
+
+// alteredTable returns the objects of the table altered by the ALTER TABLE statement the node belongs to.
+func alteredTable(c *Cursor) Objects {
+	stmt, _ := ancestorOf[*pgquery.AlterTableStmt](c)
+	return tableObjects(stmt.GetRelation())
+}
+
+// droppedObjects returns the objects of a DROP statement, the first one of them if several are dropped, and their
+// qualified names.
+func droppedObjects(stmt *pgquery.DropStmt) (Objects, []string) {
+	var objects Objects
+	var names []string
+	for i, object := range stmt.GetObjects() {
+		schema, name := splitName(object.GetList().GetItems())
+		if name == "" {
+			// Objects that aren't schema qualified are plain strings or types, e.g. schemas and extensions
+			if typ := object.GetTypeName(); typ != nil {
+				names = append(names, typeName(typ))
+			} else if str := object.GetString_(); str != nil {
+				names = append(names, str.GetSval())
+			}
+			continue
+		}
+		names = append(names, schema+"."+name)
+		if i > 0 {
+			continue
+		}
+		switch stmt.GetRemoveType() {
+		case pgquery.ObjectType_OBJECT_TABLE:
+			objects = Objects{Schema: schema, Table: name}
+		case pgquery.ObjectType_OBJECT_INDEX:
+			objects = Objects{Schema: schema, Index: name}
+		default:
+			objects = Objects{Schema: schema}
+		}
+	}
+	return objects, names
 }
@@ -0,0 +1,357 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+
+	pgquery "github.com/pganalyze/pg_query_go/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleContext(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should report with rule metadata", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := &RuleContext{
+			Rule: Rule{Code: testCode, Slug: testSlug, Help: testHelp},
+			File: "migration.sql",
+		}
+		objects := Objects{Schema: "public", Table: "pgvet"}
+		ctx.Report(&Cursor{Stmt: &pgquery.RawStmt{StmtLocation: 10, StmtLen: 10}}, Span{Start: 12, End: 18}, objects, "message")
+
+		res := ctx.Results()
+		require.Len(t, res, 1)
+		assert.Equal(t, Result{
+			Slug:      testSlug,
+			Help:      testHelp,
+			Code:      testCode,
+			Message:   "message",
+			Objects:   objects,
+			StmtStart: 10,
+			StmtEnd:   20,
+			Start:     12,
+			End:       18,
+		}, res[0])
+	})
+
+	t.Run("Should run all rules", func(t *testing.T) {
+		t.Parallel()
+
+		tree := mustParse(t, "ALTER TABLE pgvet DROP COLUMN value;")
+		var contexts []*RuleContext
+		for _, rule := range AllRules() {
+			contexts = append(contexts, &RuleContext{Rule: rule, ImplicitTransaction: true})
+		}
+		require.NoError(t, Check(tree, nil, contexts))
+
+		var codes []Code
+		for _, ctx := range contexts {
+			for _, res := range ctx.Results() {
+				codes = append(codes, res.Code)
+			}
+		}
+		assert.ElementsMatch(t, []Code{"drop-column", "missing-if-exists"}, codes)
+	})
+}
+
+func TestNewOptions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should copy the defaults", func(t *testing.T) {
+		t.Parallel()
+
+		rule, ok := Lookup("multiple-locks")
+		require.True(t, ok)
+
+		opts := rule.NewOptions()
+		require.IsType(t, &MultipleLocksOptions{}, opts)
+		assert.Equal(t, rule.Options, opts)
+
+		opts.(*MultipleLocksOptions).MaxLocks = 5
+		assert.Equal(t, 1, rule.Options.(*MultipleLocksOptions).MaxLocks)
+	})
+
+	t.Run("Should be nil without options", func(t *testing.T) {
+		t.Parallel()
+
+		rule, ok := Lookup("drop-column")
+		require.True(t, ok)
+		assert.Nil(t, rule.NewOptions())
+	})
+}
+
+// checkOptions configures how check runs a rule.
+type checkOptions struct {
+	ImplicitTransaction bool
+	// The options of the rule, its defaults if nil.
+	Options Options
+	// The targeted major version of PostgreSQL, DefaultPostgresVersion if zero.
+	PostgresVersion int
+	// The SQL the tree was parsed from, violations span the whole statement if empty.
+	Source string
+}
+
+// check runs the rule against the tree with the default options of the rule under test unless others are given.
+func check(t *testing.T, fn func(*RuleContext) error, tree *pgquery.ParseResult, opts checkOptions) ([]Result, error) {
+	t.Helper()
+
+	ctx := &RuleContext{
+		Tree:                tree,
+		Rule:                Rule{Code: testCode, Slug: testSlug, Help: testHelp, Fn: fn},
+		Options:             opts.Options,
+		File:                "migration.sql",
+		Source:              opts.Source,
+		ImplicitTransaction: opts.ImplicitTransaction,
+		PostgresVersion:     opts.PostgresVersion,
+	}
+	for _, rule := range AllRules() {
+		if reflect.ValueOf(rule.Fn).Pointer() == reflect.ValueOf(fn).Pointer() {
+			ctx.Rule.Options = rule.Options
+		}
+	}
+
+	err := Check(tree, nil, []*RuleContext{ctx})
+	return ctx.Results(), err
+}
+
+func TestSpans(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		fn       func(*RuleContext) error
+		sql      string
+		version  int
+		expected []string
+	}{
+		{
+			name:     "Statement without comments",
+			fn:       dropTable,
+			sql:      "SELECT 1;\n-- comment\nDROP TABLE pgvet;\n",
+			expected: []string{"DROP TABLE pgvet"},
+		},
+		{
+			name:     "Last statement without semicolon",
+			fn:       dropTable,
+			sql:      "SELECT 1;\nDROP TABLE pgvet\n",
+			expected: []string{"DROP TABLE pgvet"},
+		},
+		{
+			name:     "Sub-commands",
+			fn:       dropColumn,
+			sql:      "ALTER TABLE public.pgvet ADD COLUMN a int, DROP COLUMN IF EXISTS b,\n  DROP c;",
+			expected: []string{"DROP COLUMN IF EXISTS b", "DROP c"},
+		},
+		{
+			name:     "Sub-command with parentheses",
+			fn:       constraintExcessiveLock,
+			sql:      "ALTER TABLE ONLY pgvet ADD CONSTRAINT a CHECK (x IN (1, 2)), ADD CONSTRAINT b CHECK (y > 0);",
+			expected: []string{"ADD CONSTRAINT a CHECK (x IN (1, 2))", "ADD CONSTRAINT b CHECK (y > 0)"},
+		},
+		{
+			name:     "Not null constraint",
+			fn:       addNonNullColumn,
+			sql:      "ALTER TABLE pgvet ADD COLUMN name text NOT NULL CHECK (name <> '');",
+			expected: []string{"NOT NULL"},
+		},
+		{
+			name:     "Column definition",
+			fn:       useTimestampWithTimeZone,
+			sql:      "CREATE TABLE pgvet (id int, created_at timestamp NOT NULL, name text);",
+			expected: []string{"created_at timestamp NOT NULL"},
+		},
+		{
+			name:     "Partition sub-command",
+			fn:       unsupportedFeature,
+			sql:      "ALTER TABLE pgvet DETACH PARTITION pgvet_2024 CONCURRENTLY;",
+			version:  13,
+			expected: []string{"DETACH PARTITION pgvet_2024 CONCURRENTLY"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			res, err := check(t, tc.fn, mustParse(t, tc.sql), checkOptions{Source: tc.sql, PostgresVersion: tc.version})
+			require.NoError(t, err)
+			var spans []string
+			for _, res := range res {
+				spans = append(spans, tc.sql[res.Start:res.End])
+			}
+			assert.Equal(t, tc.expected, spans)
+		})
+	}
+}
+
+func TestSubcommands(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should identify the sub-commands", func(t *testing.T) {
+		t.Parallel()
+
+		sql := "ALTER TABLE pgvet DROP COLUMN a, ADD COLUMN b int, DROP COLUMN c,\n  DROP COLUMN d;"
+		res, err := check(t, dropColumn, mustParse(t, sql), checkOptions{Source: sql})
+		require.NoError(t, err)
+		var subcommands []int
+		for _, res := range res {
+			subcommands = append(subcommands, res.Subcommand)
+		}
+		assert.Equal(t, []int{1, 3, 4}, subcommands)
+	})
+
+	t.Run("Should identify nested clauses", func(t *testing.T) {
+		t.Parallel()
+
+		sql := "ALTER TABLE pgvet ADD COLUMN IF NOT EXISTS a int, ADD COLUMN IF NOT EXISTS b int NOT NULL;"
+		res, err := check(t, addNonNullColumn, mustParse(t, sql), checkOptions{Source: sql})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		assert.Equal(t, 2, res[0].Subcommand)
+	})
+
+	t.Run("Should be zero for the statement", func(t *testing.T) {
+		t.Parallel()
+
+		sql := "DROP TABLE pgvet;"
+		res, err := check(t, dropTable, mustParse(t, sql), checkOptions{Source: sql})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		assert.Zero(t, res[0].Subcommand)
+	})
+}
+
+func TestMessages(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		fn      func(*RuleContext) error
+		sql     string
+		version int
+		message string
+		objects Objects
+	}{
+		{
+			name:    "Drop column",
+			fn:      dropColumn,
+			sql:     "ALTER TABLE users DROP COLUMN email;",
+			message: "Dropping column public.users.email",
+			objects: Objects{Schema: "public", Table: "users", Column: "email"},
+		},
+		{
+			name:    "Drop tables",
+			fn:      dropTable,
+			sql:     "DROP TABLE app.users, app.groups;",
+			message: "Dropping table app.users, app.groups",
+			objects: Objects{Schema: "app", Table: "users"},
+		},
+		{
+			name:    "Rename column",
+			fn:      renameColumn,
+			sql:     "ALTER TABLE users RENAME COLUMN email TO mail;",
+			message: "Renaming column public.users.email to mail",
+			objects: Objects{Schema: "public", Table: "users", Column: "email"},
+		},
+		{
+			name:    "Change column type",
+			fn:      changeColumnType,
+			sql:     "ALTER TABLE users ALTER COLUMN id TYPE bigint;",
+			message: "Changing the type of column public.users.id to int8",
+			objects: Objects{Schema: "public", Table: "users", Column: "id"},
+		},
+		{
+			name:    "Add non-null column",
+			fn:      addNonNullColumn,
+			sql:     "ALTER TABLE users ADD COLUMN email text NOT NULL;",
+			message: "Adding non-nullable column public.users.email without a default",
+			objects: Objects{Schema: "public", Table: "users", Column: "email"},
+		},
+		{
+			name:    "Non-concurrent index",
+			fn:      nonConcurrentIndex,
+			sql:     "CREATE INDEX users_email_idx ON users(email);",
+			message: "Index users_email_idx is created without CONCURRENTLY",
+			objects: Objects{Schema: "public", Table: "users", Index: "users_email_idx"},
+		},
+		{
+			name:    "Non-concurrent reindex",
+			fn:      nonConcurrentIndex,
+			sql:     "REINDEX TABLE users;",
+			message: "Reindexing table public.users without CONCURRENTLY",
+			objects: Objects{Schema: "public", Table: "users"},
+		},
+		{
+			name:    "Constraint excessive lock",
+			fn:      constraintExcessiveLock,
+			sql:     "ALTER TABLE users ADD CONSTRAINT users_group_fk FOREIGN KEY (group_id) REFERENCES groups(id);",
+			message: "Constraint users_group_fk is added to table public.users without NOT VALID",
+			objects: Objects{Schema: "public", Table: "users", Constraint: "users_group_fk"},
+		},
+		{
+			name:    "Missing if exists",
+			fn:      missingIfExists,
+			sql:     "DROP MATERIALIZED VIEW stats;",
+			message: "Dropping materialized view public.stats without IF EXISTS",
+			objects: Objects{Schema: "public"},
+		},
+		{
+			name:    "Missing foreign key index",
+			fn:      missingForeignKeyIndex,
+			sql:     "CREATE TABLE IF NOT EXISTS users (id int, group_id int REFERENCES groups(id));",
+			message: "Foreign key column public.users.group_id has no index",
+			objects: Objects{Schema: "public", Table: "users", Column: "group_id"},
+		},
+		{
+			name:    "Timestamp",
+			fn:      useTimestampWithTimeZone,
+			sql:     "ALTER TABLE users ADD COLUMN created_at timestamp;",
+			message: "Column public.users.created_at has type timestamp",
+			objects: Objects{Schema: "public", Table: "users", Column: "created_at"},
+		},
+		{
+			name:    "Unsupported feature",
+			fn:      unsupportedFeature,
+			sql:     "REINDEX INDEX CONCURRENTLY users_email_idx;",
+			version: 11,
+			message: "Reindexing index public.users_email_idx concurrently is not supported by PostgreSQL 11",
+			objects: Objects{Schema: "public", Index: "users_email_idx"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := check(t, tc.fn, mustParse(t, tc.sql), checkOptions{Source: tc.sql, PostgresVersion: tc.version})
+			require.NoError(t, err)
+			require.Len(t, res, 1)
+			assert.Equal(t, tc.message, res[0].Message)
+			assert.Equal(t, tc.objects, res[0].Objects)
+		})
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, SeverityError.AtLeast(SeverityWarning))
+	assert.True(t, SeverityWarning.AtLeast(SeverityWarning))
+	assert.False(t, SeverityInfo.AtLeast(SeverityWarning))
+	assert.False(t, Severity("critical").Valid())
+
+	for _, rule := range AllRules() {
+		assert.True(t, rule.Severity.Valid(), rule.Code)
+	}
+}
+
+func mustParse(t *testing.T, q string) *pgquery.ParseResult {
+	t.Helper()
+
+	tree, err := pgquery.Parse(q)
+	require.NoError(t, err)
+
+	return tree
+}
@@ -0,0 +1,147 @@
+package rules
+
+import (
+	"strings"
+
+	pgquery "github.com/pganalyze/pg_query_go/v6"
+)
+
+// Span is a range of the source, e.g. the clause of a statement a violation is about.
+type Span struct {
+	Start int32
+	End   int32
+}
+
+// stmtEnd returns the end of the statement of the cursor, excluding the terminating semicolon.
+func (c *RuleContext) stmtEnd(cur *Cursor) int32 {
+	return stmtEnd(c.Source, cur.Stmt)
+}
+
+// stmtEnd returns the end of the statement in the source, excluding the terminating semicolon.
+func stmtEnd(source string, stmt *pgquery.RawStmt) int32 {
+	// The length is zero for a last statement without a semicolon
+	if stmt.GetStmtLen() == 0 && source != "" {
+		return int32(len(strings.TrimRight(source, " \t\r\n")))
+	}
+	return stmt.GetStmtLocation() + stmt.GetStmtLen()
+}
+
+// stmtSpan returns the span of the statement of the cursor, leaving out the comments and whitespace around it.
+func (c *RuleContext) stmtSpan(cur *Cursor) Span {
+	if c.Source == "" {
+		return Span{Start: cur.StmtStart(), End: cur.StmtEnd()}
+	}
+	tokens := lex(c.Source, cur.StmtStart(), c.stmtEnd(cur))
+	if len(tokens) == 0 {
+		return Span{Start: cur.StmtStart(), End: c.stmtEnd(cur)}
+	}
+	return Span{Start: tokens[0].start, End: tokens[len(tokens)-1].end}
+}
+
+// clauseSpan returns the span of the clause starting at location. The clause ends before the next comma outside of
+// parentheses, before the parenthesis closing the list the clause is in, or at the end of the statement.
+func (c *RuleContext) clauseSpan(cur *Cursor, location int32) Span {
+	if location < 0 || c.Source == "" {
+		return c.stmtSpan(cur)
+	}
+	tokens := lex(c.Source, location, c.stmtEnd(cur))
+	if len(tokens) == 0 {
+		return c.stmtSpan(cur)
+	}
+	return Span{Start: tokens[0].start, End: clauseEnd(tokens)}
+}
+
+// clauseEnd returns the end of the clause the tokens start with.
+func clauseEnd(tokens []token) int32 {
+	depth := 0
+	for i, tok := range tokens {
+		switch tok.text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth < 0 && i > 0 {
+				return tokens[i-1].end
+			}
+		case ",", ";":
+			if depth == 0 && i > 0 {
+				return tokens[i-1].end
+			}
+		}
+	}
+	return tokens[len(tokens)-1].end
+}
+
+// cmdSpan returns the span of the sub-command of the ALTER statement of the cursor, e.g. DROP COLUMN name.
+func (c *RuleContext) cmdSpan(cur *Cursor, cmd *pgquery.AlterTableCmd) Span {
+	stmt := cur.Stmt.GetStmt().GetAlterTableStmt()
+	spans := c.cmdSpans(cur)
+	for i, node := range stmt.GetCmds() {
+		if node.GetAlterTableCmd() == cmd && i < len(spans) {
+			return spans[i]
+		}
+	}
+	return c.stmtSpan(cur)
+}
+
+// cmdSpans returns the spans of the sub-commands of the ALTER statement of the cursor, nil if the statement isn't an
+// ALTER statement or the sub-commands can't be told apart.
+func (c *RuleContext) cmdSpans(cur *Cursor) []Span {
+	stmt := cur.Stmt.GetStmt().GetAlterTableStmt()
+	if stmt == nil || c.Source == "" {
+		return nil
+	}
+
+	// The sub-commands follow the possibly qualified name of the relation and are separated by commas
+	tokens := lex(c.Source, stmt.GetRelation().GetLocation(), c.stmtEnd(cur))
+	i := 1
+	for i+1 < len(tokens) && tokens[i].text == "." {
+		i += 2
+	}
+	if i < len(tokens) && tokens[i].text == "*" {
+		i++
+	}
+
+	var spans []Span
+	for i < len(tokens) && tokens[i].text != ";" {
+		end := clauseEnd(tokens[i:])
+		spans = append(spans, Span{Start: tokens[i].start, End: end})
+		for i < len(tokens) && tokens[i].end <= end {
+			i++
+		}
+		// Skip the comma separating the sub-commands
+		i++
+	}
+	if len(spans) != len(stmt.GetCmds()) {
+		return nil
+	}
+	return spans
+}
+
+// subcommand returns the position of the sub-command of the ALTER statement of the cursor the span is in, starting at
+// 1, or zero if the span isn't within a sub-command.
+func (c *RuleContext) subcommand(cur *Cursor, span Span) int {
+	for i, cmd := range c.cmdSpans(cur) {
+		if span.Start >= cmd.Start && span.End <= cmd.End {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// constraintSpan returns the span of a constraint of a column definition, e.g. NOT NULL. The constraint ends where the
+// next one starts.
+func (c *RuleContext) constraintSpan(cur *Cursor, col *pgquery.ColumnDef, constraint *pgquery.Constraint) Span {
+	span := c.clauseSpan(cur, constraint.GetLocation())
+	if constraint.GetLocation() < 0 || c.Source == "" {
+		return span
+	}
+	for _, other := range col.GetConstraints() {
+		location := other.GetConstraint().GetLocation()
+		if location > span.Start && location < span.End {
+			tokens := lex(c.Source, span.Start, location)
+			span.End = tokens[len(tokens)-1].end
+		}
+	}
+	return span
+}
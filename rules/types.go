@@ -1,6 +1,8 @@
 package rules
 
 import (
+	"fmt"
+
 	pgquery "github.com/pganalyze/pg_query_go/v6"
 )
 
@@ -10,59 +12,128 @@ var typeRules = []Rule{
 		Slug:     "Timestamp with time zone preserves the time zone information and makes the data easier to reason about",
 		Help:     "Update fields to use `timestamptz`/`timestamp with time zone` instead of `timestamp`/`timestamp without time zone`",
 		Fn:       useTimestampWithTimeZone,
+		Fix:      fixUseTimestampWithTimeZone,
 		Category: "types",
+		Severity: SeverityInfo,
+		Options:  &UseTimestampWithTimeZoneOptions{AllowedTypes: []string{"timestamptz", "time", "timetz"}},
 	},
 }
 
-func useTimestampWithTimeZone(
-	tree *pgquery.ParseResult,
-	code Code,
-	slug,
-	help string,
-	implicitTransaction bool,
-) ([]Result, error) {
-	var results []Result
-	for _, stmt := range tree.Stmts {
-		// Check for table creation
-		createStmt := stmt.GetStmt().GetCreateStmt()
-		for _, col := range createStmt.GetTableElts() {
-			for _, nameNode := range col.GetColumnDef().GetTypeName().GetNames() {
-				if name := nameNode.GetString_(); name != nil {
-					if name.Sval == "timestamp" {
-						r := Result{
-							Slug:      slug,
-							Help:      help,
-							Code:      code,
-							StmtStart: stmt.GetStmtLocation(),
-							StmtEnd:   stmt.GetStmtLocation() + stmt.GetStmtLen(),
-						}
-						results = append(results, r)
-					}
+// Maps the names of the date/time types to their internal names used in the parse tree.
+var timeTypes = map[string]string{
+	"timestamp":                   "timestamp",
+	"timestamp without time zone": "timestamp",
+	"timestamptz":                 "timestamptz",
+	"timestamp with time zone":    "timestamptz",
+	"time":                        "time",
+	"time without time zone":      "time",
+	"timetz":                      "timetz",
+	"time with time zone":         "timetz",
+}
+
+// UseTimestampWithTimeZoneOptions are the options of the use-timestamp-with-time-zone rule.
+type UseTimestampWithTimeZoneOptions struct {
+	// The date/time types that columns are allowed to use.
+	AllowedTypes []string `yaml:"allowedTypes"`
+}
+
+func (o *UseTimestampWithTimeZoneOptions) Validate() error {
+	for _, typ := range o.AllowedTypes {
+		if _, ok := timeTypes[typ]; !ok {
+			return fmt.Errorf("unknown type %q in allowedTypes", typ)
+		}
+	}
+	return nil
+}
+
+func (o *UseTimestampWithTimeZoneOptions) allowed(typeName string) bool {
+	internal, isTimeType := timeTypes[typeName]
+	if !isTimeType {
+		return true
+	}
+	for _, typ := range o.AllowedTypes {
+		if timeTypes[typ] == internal {
+			return true
+		}
+	}
+	return false
+}
+
+func useTimestampWithTimeZone(ctx *RuleContext) error {
+	opts := optionsOf[*UseTimestampWithTimeZoneOptions](ctx)
+
+	On(ctx, func(c *Cursor, col *pgquery.ColumnDef) {
+		// Only check columns of created tables and added columns
+		var objects Objects
+		switch parent := c.Parent().(type) {
+		case *pgquery.CreateStmt:
+			objects = tableObjects(parent.GetRelation())
+		case *pgquery.AlterTableCmd:
+			if parent.GetSubtype() != pgquery.AlterTableType_AT_AddColumn {
+				return
+			}
+			objects = alteredTable(c)
+		default:
+			return
+		}
+		objects.Column = col.GetColname()
+
+		for _, nameNode := range col.GetTypeName().GetNames() {
+			if name := nameNode.GetString_(); name != nil {
+				if !opts.allowed(name.Sval) {
+					message := fmt.Sprintf("Column %s has type %s", objects.qualifiedColumn(), typeName(col.GetTypeName()))
+					ctx.Report(c, ctx.clauseSpan(c, col.GetLocation()), objects, message)
 				}
 			}
 		}
+	})
+	return nil
+}
 
-		// Check for add column
-		alterTableStmt := stmt.GetStmt().GetAlterTableStmt()
-		for _, cmd := range alterTableStmt.GetCmds() {
-			if cmd.GetAlterTableCmd().GetSubtype() != pgquery.AlterTableType_AT_AddColumn {
-				continue
+func fixUseTimestampWithTimeZone(ctx *FixContext) []Edit {
+	opts := optionsOf[*UseTimestampWithTimeZoneOptions](ctx.RuleContext)
+	if !opts.allowed("timestamptz") {
+		return nil
+	}
+
+	var columns []*pgquery.ColumnDef
+	switch stmt := ctx.Stmt.GetStmt().GetNode().(type) {
+	case *pgquery.Node_CreateStmt:
+		for _, elt := range stmt.CreateStmt.GetTableElts() {
+			columns = append(columns, elt.GetColumnDef())
+		}
+	case *pgquery.Node_AlterTableStmt:
+		for _, cmd := range stmt.AlterTableStmt.GetCmds() {
+			if cmd.GetAlterTableCmd().GetSubtype() == pgquery.AlterTableType_AT_AddColumn {
+				columns = append(columns, cmd.GetAlterTableCmd().GetDef().GetColumnDef())
 			}
-			for _, nameNode := range cmd.GetAlterTableCmd().GetDef().GetColumnDef().GetTypeName().GetNames() {
-				if name := nameNode.GetString_(); name != nil {
-					if name.Sval == "timestamp" {
-						r := Result{
-							Slug:      slug,
-							Help:      help,
-							Code:      code,
-							StmtStart: stmt.GetStmtLocation(),
-							StmtEnd:   stmt.GetStmtLocation() + stmt.GetStmtLen(),
-						}
-						results = append(results, r)
-					}
-				}
+		}
+	}
+
+	var edits []Edit
+	for _, col := range columns {
+		typ := col.GetTypeName()
+		names := stringValues(typ.GetNames())
+		if len(names) == 0 || timeTypes[names[len(names)-1]] != "timestamp" {
+			continue
+		}
+		tokens := lex(ctx.Source, typ.GetLocation(), ctx.StmtEnd())
+		if len(tokens) == 0 || !tokens[0].is("timestamp") {
+			continue
+		}
+		edits = append(edits, Edit{Start: tokens[0].start, End: tokens[0].end, Text: "timestamptz"})
+
+		// Skip the precision, e.g. timestamp(3), and remove WITHOUT TIME ZONE
+		last := 0
+		if len(tokens) > 1 && tokens[1].text == "(" {
+			for last < len(tokens)-1 && tokens[last].text != ")" {
+				last++
 			}
 		}
+		rest := tokens[last+1:]
+		if len(rest) >= 3 && rest[0].is("without") && rest[1].is("time") && rest[2].is("zone") {
+			edits = append(edits, Edit{Start: tokens[last].end, End: rest[2].end})
+		}
 	}
-	return results, nil
+	return edits
 }
@@ -17,7 +17,7 @@ func TestUseTimestampWithTimeZone(t *testing.T) {
 		tree := mustParse(t, "CREATE TABLE pgvet (created_at timestamp);")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := useTimestampWithTimeZone(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, useTimestampWithTimeZone, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 1)
 
@@ -34,7 +34,7 @@ func TestUseTimestampWithTimeZone(t *testing.T) {
 		tree := mustParse(t, "ALTER TABLE pgvet ADD COLUMN created_at timestamp;")
 		require.Len(t, tree.Stmts, 1)
 
-		res, err := useTimestampWithTimeZone(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, useTimestampWithTimeZone, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 1)
 
@@ -55,7 +55,7 @@ func TestUseTimestampWithTimeZone(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 3)
 
-		res, err := useTimestampWithTimeZone(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, useTimestampWithTimeZone, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		require.Len(t, res, 2)
 
@@ -72,8 +72,44 @@ func TestUseTimestampWithTimeZone(t *testing.T) {
 		tree := mustParse(t, b.String())
 		require.Len(t, tree.Stmts, 2)
 
-		res, err := dropColumn(tree, testCode, testSlug, testHelp, true)
+		res, err := check(t, dropColumn, tree, checkOptions{ImplicitTransaction: true})
 		require.NoError(t, err)
 		assert.Empty(t, res)
 	})
+	t.Run("Should find violations for disallowed types", func(t *testing.T) {
+		t.Parallel()
+
+		var b strings.Builder
+		b.WriteString("CREATE TABLE pgvet (created_at timestamptz, starts_at time);\n")
+		b.WriteString("ALTER TABLE pgvet ADD COLUMN ends_at time with time zone;\n")
+		tree := mustParse(t, b.String())
+		require.Len(t, tree.Stmts, 2)
+
+		opts := &UseTimestampWithTimeZoneOptions{AllowedTypes: []string{"timestamp with time zone"}}
+		res, err := check(t, useTimestampWithTimeZone, tree, checkOptions{ImplicitTransaction: true, Options: opts})
+		require.NoError(t, err)
+		require.Len(t, res, 2)
+
+		assert.EqualValues(t, 0, res[0].StmtStart)
+		assert.EqualValues(t, 60, res[1].StmtStart)
+	})
+
+	t.Run("Should find no violations for allowed types", func(t *testing.T) {
+		t.Parallel()
+
+		tree := mustParse(t, "CREATE TABLE pgvet (created_at timestamp);")
+		require.Len(t, tree.Stmts, 1)
+
+		opts := &UseTimestampWithTimeZoneOptions{AllowedTypes: []string{"timestamp"}}
+		res, err := check(t, useTimestampWithTimeZone, tree, checkOptions{ImplicitTransaction: true, Options: opts})
+		require.NoError(t, err)
+		assert.Empty(t, res)
+	})
+}
+
+func TestUseTimestampWithTimeZoneOptions(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, (&UseTimestampWithTimeZoneOptions{AllowedTypes: []string{"timestamptz", "time with time zone"}}).Validate())
+	require.Error(t, (&UseTimestampWithTimeZoneOptions{AllowedTypes: []string{"date"}}).Validate())
 }
@@ -0,0 +1,183 @@
+package rules
+
+import (
+	"fmt"
+
+	pgquery "github.com/pganalyze/pg_query_go/v6"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Cursor describes where in the tree a visited node is.
+type Cursor struct {
+	// The top level statement the node belongs to.
+	Stmt *pgquery.RawStmt
+	// The ancestors of the node, the closest one last. Node wrappers are left out.
+	ancestors []proto.Message
+}
+
+// StmtStart returns the start of the top level statement the node belongs to.
+func (c *Cursor) StmtStart() int32 {
+	return c.Stmt.GetStmtLocation()
+}
+
+// StmtEnd returns the end of the top level statement the node belongs to.
+func (c *Cursor) StmtEnd() int32 {
+	return c.Stmt.GetStmtLocation() + c.Stmt.GetStmtLen()
+}
+
+// Parent returns the closest ancestor of the node, or nil for a top level statement.
+func (c *Cursor) Parent() proto.Message {
+	if len(c.ancestors) == 0 {
+		return nil
+	}
+	return c.ancestors[len(c.ancestors)-1]
+}
+
+// ancestorOf returns the closest ancestor of the node that is of type T.
+func ancestorOf[T proto.Message](c *Cursor) (T, bool) {
+	for i := len(c.ancestors) - 1; i >= 0; i-- {
+		if ancestor, ok := c.ancestors[i].(T); ok {
+			return ancestor, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// walker traverses every node of a parse tree once and calls the handlers registered for each node type.
+type walker struct {
+	handlers    map[protoreflect.FullName][]func(*Cursor, proto.Message)
+	endHandlers []func()
+	// Called after each top level statement has been walked.
+	stmtEndHandlers []func(*pgquery.RawStmt)
+	// Whether the statement being walked runs inside a transaction.
+	tx txTracker
+}
+
+func newWalker() *walker {
+	return &walker{handlers: map[protoreflect.FullName][]func(*Cursor, proto.Message){}}
+}
+
+// On registers fn to be called for every node of type T in the tree, including nested nodes.
+// Nodes are visited in the order they appear in the statements.
+func On[T proto.Message](ctx *RuleContext, fn func(*Cursor, T)) {
+	var zero T
+	name := zero.ProtoReflect().Descriptor().FullName()
+	ctx.walker.handlers[name] = append(ctx.walker.handlers[name], func(c *Cursor, msg proto.Message) {
+		fn(c, msg.(T))
+	})
+}
+
+// OnEnd registers fn to be called when the whole tree has been walked.
+func OnEnd(ctx *RuleContext, fn func()) {
+	ctx.walker.endHandlers = append(ctx.walker.endHandlers, fn)
+}
+
+func (w *walker) walk(tree *pgquery.ParseResult) {
+	c := &Cursor{}
+	for _, stmt := range tree.GetStmts() {
+		c.Stmt = stmt
+		if stmt.GetStmt() != nil {
+			w.visit(c, stmt.GetStmt().ProtoReflect())
+		}
+		w.tx.apply(stmt.GetStmt())
+		for _, fn := range w.stmtEndHandlers {
+			fn(stmt)
+		}
+	}
+	for _, fn := range w.endHandlers {
+		fn()
+	}
+}
+
+// txTracker tracks whether the statements run inside a transaction.
+type txTracker struct {
+	inTx bool
+}
+
+// apply updates the tracker with the statement.
+func (t *txTracker) apply(stmt *pgquery.Node) {
+	if inTx, ok := transactionBoundary(stmt.GetTransactionStmt()); ok {
+		t.inTx = inTx
+	}
+}
+
+// transactionBoundary returns whether a transaction is open after the statement, ok is false if the statement neither
+// begins nor ends a transaction.
+func transactionBoundary(stmt *pgquery.TransactionStmt) (inTx bool, ok bool) {
+	switch stmt.GetKind() {
+	case pgquery.TransactionStmtKind_TRANS_STMT_BEGIN, pgquery.TransactionStmtKind_TRANS_STMT_START:
+		return true, true
+	case pgquery.TransactionStmtKind_TRANS_STMT_COMMIT, pgquery.TransactionStmtKind_TRANS_STMT_ROLLBACK:
+		// AND CHAIN starts a new transaction right away
+		return stmt.GetChain(), true
+	case pgquery.TransactionStmtKind_TRANS_STMT_PREPARE:
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+var nodeName = (*pgquery.Node)(nil).ProtoReflect().Descriptor().FullName()
+
+func (w *walker) visit(c *Cursor, msg protoreflect.Message) {
+	isNode := msg.Descriptor().FullName() == nodeName
+	if !isNode {
+		for _, fn := range w.handlers[msg.Descriptor().FullName()] {
+			fn(c, msg.Interface())
+		}
+		c.ancestors = append(c.ancestors, msg.Interface())
+	}
+
+	msg.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		if field.Kind() != protoreflect.MessageKind {
+			return true
+		}
+		if field.IsList() {
+			list := value.List()
+			for i := range list.Len() {
+				w.visit(c, list.Get(i).Message())
+			}
+			return true
+		}
+		w.visit(c, value.Message())
+		return true
+	})
+
+	if !isNode {
+		c.ancestors = c.ancestors[:len(c.ancestors)-1]
+	}
+}
+
+// Check runs the rules of the contexts against the tree in a single walk.
+// The results are collected in each context.
+//
+// The catalog is updated with each statement after the rules have visited it, so rules see the schema as it was
+// before the statement. Pass the catalog the previous files were checked with to carry the schema over.
+func Check(tree *pgquery.ParseResult, catalog *Catalog, contexts []*RuleContext) error {
+	if catalog == nil {
+		catalog = NewCatalog()
+	}
+
+	w := newWalker()
+	var file string
+	if len(contexts) > 0 {
+		file = contexts[0].File
+		w.tx.inTx = contexts[0].ImplicitTransaction
+	}
+	w.stmtEndHandlers = append(w.stmtEndHandlers, func(stmt *pgquery.RawStmt) {
+		catalog.Apply(stmt.GetStmt(), file)
+	})
+	for _, ctx := range contexts {
+		ctx.Tree = tree
+		ctx.Catalog = catalog
+		ctx.walker = w
+		if err := ctx.Rule.Fn(ctx); err != nil {
+			return fmt.Errorf("rule %q: %w", ctx.Rule.Code, err)
+		}
+	}
+	w.walk(tree)
+	catalog.endFile()
+	return nil
+}
@@ -0,0 +1,92 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+
+	pgquery "github.com/pganalyze/pg_query_go/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalk(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Should visit nodes in statement order", func(t *testing.T) {
+		t.Parallel()
+
+		var b strings.Builder
+		b.WriteString("ALTER TABLE pgvet ADD COLUMN value text, DROP COLUMN other;\n")
+		b.WriteString("ALTER TABLE pgvet ALTER COLUMN value SET NOT NULL;\n")
+		tree := mustParse(t, b.String())
+		require.Len(t, tree.Stmts, 2)
+
+		var subtypes []pgquery.AlterTableType
+		var starts []int32
+		ctx := &RuleContext{Rule: Rule{Fn: func(ctx *RuleContext) error {
+			On(ctx, func(c *Cursor, cmd *pgquery.AlterTableCmd) {
+				subtypes = append(subtypes, cmd.GetSubtype())
+				starts = append(starts, c.StmtStart())
+			})
+			return nil
+		}}}
+		require.NoError(t, Check(tree, nil, []*RuleContext{ctx}))
+
+		assert.Equal(t, []pgquery.AlterTableType{
+			pgquery.AlterTableType_AT_AddColumn,
+			pgquery.AlterTableType_AT_DropColumn,
+			pgquery.AlterTableType_AT_SetNotNull,
+		}, subtypes)
+		assert.Equal(t, []int32{0, 0, 59}, starts)
+	})
+
+	t.Run("Should visit nested statements", func(t *testing.T) {
+		t.Parallel()
+
+		tree := mustParse(t, "CREATE SCHEMA pgvet CREATE TABLE pgvet (id int) CREATE INDEX pgvet_idx ON pgvet(id);")
+		require.Len(t, tree.Stmts, 1)
+
+		res, err := check(t, missingIfNotExists, tree, checkOptions{ImplicitTransaction: true})
+		require.NoError(t, err)
+		assert.Len(t, res, 2)
+	})
+
+	t.Run("Should expose ancestors", func(t *testing.T) {
+		t.Parallel()
+
+		tree := mustParse(t, "ALTER TABLE pgvet ADD COLUMN value text NOT NULL;")
+		require.Len(t, tree.Stmts, 1)
+
+		var relname string
+		var parent any
+		ctx := &RuleContext{Rule: Rule{Fn: func(ctx *RuleContext) error {
+			On(ctx, func(c *Cursor, _ *pgquery.Constraint) {
+				stmt, ok := ancestorOf[*pgquery.AlterTableStmt](c)
+				require.True(t, ok)
+				relname = stmt.GetRelation().GetRelname()
+				parent = c.Parent()
+			})
+			return nil
+		}}}
+		require.NoError(t, Check(tree, nil, []*RuleContext{ctx}))
+
+		assert.Equal(t, "pgvet", relname)
+		assert.IsType(t, &pgquery.ColumnDef{}, parent)
+	})
+
+	t.Run("Should call end handlers after the walk", func(t *testing.T) {
+		t.Parallel()
+
+		tree := mustParse(t, "DROP TABLE pgvet; DROP TABLE pgvet2;")
+
+		var events []string
+		ctx := &RuleContext{Rule: Rule{Fn: func(ctx *RuleContext) error {
+			OnEnd(ctx, func() { events = append(events, "end") })
+			On(ctx, func(_ *Cursor, _ *pgquery.DropStmt) { events = append(events, "drop") })
+			return nil
+		}}}
+		require.NoError(t, Check(tree, nil, []*RuleContext{ctx}))
+
+		assert.Equal(t, []string{"drop", "drop", "end"}, events)
+	})
+}
@@ -22,3 +22,7 @@ ALTER TABLE pgvet ALTER COLUMN value TYPE text;
 
 -- pgvet_nolint:change-column-type
 ALTER TABLE pgvet ALTER COLUMN value TYPE text;
+
+ALTER TABLE pgvet
+  DROP COLUMN IF EXISTS first, DROP COLUMN IF EXISTS second,
+  DROP COLUMN IF EXISTS third;
@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS pgvet (id int PRIMARY KEY, parent_id int REFERENCES parent(id));
@@ -0,0 +1 @@
+CREATE INDEX CONCURRENTLY IF NOT EXISTS pgvet_parent_id_idx ON pgvet(parent_id);
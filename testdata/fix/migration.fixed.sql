@@ -0,0 +1,18 @@
+-- Create the table
+CREATE TABLE IF NOT EXISTS pgvet (
+  id text PRIMARY KEY,
+  reference text,
+  created timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS pgvet_reference_idx ON pgvet(reference);
+
+ALTER TABLE other ADD COLUMN IF NOT EXISTS value text;
+
+/* keep this one */
+-- pgvet_nolint:missing-if-exists
+DROP TABLE legacy;
+
+ALTER TABLE other ADD CONSTRAINT reference_fk FOREIGN KEY (reference) REFERENCES pgvet(id);
+
+DROP INDEX IF EXISTS other_idx;
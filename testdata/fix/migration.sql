@@ -0,0 +1,18 @@
+-- Create the table
+CREATE TABLE pgvet (
+  id text PRIMARY KEY,
+  reference text,
+  created timestamp NOT NULL
+);
+
+CREATE INDEX pgvet_reference_idx ON pgvet(reference);
+
+ALTER TABLE other ADD COLUMN value text;
+
+/* keep this one */
+-- pgvet_nolint:missing-if-exists
+DROP TABLE legacy;
+
+ALTER TABLE other ADD CONSTRAINT reference_fk FOREIGN KEY (reference) REFERENCES pgvet(id);
+
+DROP INDEX other_idx;
@@ -0,0 +1,3 @@
+ALTER TABLE pgvet ADD COLUMN IF NOT EXISTS value text DEFAULT 'value';
+
+REINDEX INDEX CONCURRENTLY pgvet_idx;